@@ -11,6 +11,9 @@ var (
 	ErrNotSupportProcess = errors.New("[builder] not support process")
 	ErrProcessOrder      = errors.New("[builder] process order error")
 	ErrProcessSet        = errors.New("[builder] process set error")
+	ErrProcessInsert     = errors.New("[builder] process insert error")
+	ErrProcessCompound   = errors.New("[builder] compound requires at least two selectors")
+	ErrProcessIndexHint  = errors.New("[builder] index hint does not match any joined table")
 )
 
 var (
@@ -33,37 +36,63 @@ func putStrBuilder(str *strings.Builder) {
 }
 
 var selectSeq = []func(*Selector) (string, error){
+	(*Selector).processWith,
 	(*Selector).processSelect,
-	(*Selector).processForceIndex,
+	(*Selector).processIndexHints,
 	(*Selector).processJoins,
 	(*Selector).processWhere,
 	(*Selector).processGroup,
 	(*Selector).processHaving,
 	(*Selector).processOrder,
 	(*Selector).processLimit,
-	(*Selector).processOffset,
+	(*Selector).processLock,
 	(*Selector).processTail,
 }
 
 var updateSeq = []func(*Updater) (string, error){
+	(*Updater).processWith,
 	(*Updater).processUpdate,
 	(*Updater).processJoins,
 	(*Updater).processSet,
 	(*Updater).processWhere,
+	(*Updater).processLimit,
+	(*Updater).processReturning,
 }
 
 var deleteSeq = []func(*Deleter) (string, error){
+	(*Deleter).processWith,
 	(*Deleter).processDelete,
+	(*Deleter).processJoins,
 	(*Deleter).processWhere,
+	(*Deleter).processLimit,
+	(*Deleter).processReturning,
+}
+
+var compoundSeq = []func(*Compound) (string, error){
+	(*Compound).processSelectors,
+	(*Compound).processOrder,
+	(*Compound).processLimit,
 }
 
 var insertSeq = []func(*Inserter) (string, error){
 	(*Inserter).processInsert,
+	(*Inserter).processDuplicate,
+	(*Inserter).processReturning,
 }
 
 func (s *Selector) Build() (string, []interface{}, error) {
+	query, params, err := s.build()
+	if err != nil {
+		return "", nil, err
+	}
+	return rebind(s.getDialect(), query), params, nil
+}
+
+// build 生成未经方言转换的语句，作为子查询时以此嵌入外层语句
+func (s *Selector) build() (string, []interface{}, error) {
 	var sqlStr = getStrBuilder()
 	defer putStrBuilder(sqlStr)
+	s.params = nil
 	for _, m := range selectSeq {
 		sql, err := m(s)
 		if err != nil {
@@ -78,9 +107,23 @@ func (s *Selector) Build() (string, []interface{}, error) {
 	return sqlStr.String(), s.params, nil
 }
 
+// buildIn 以外层语句的方言生成作为子查询的语句，dialect为nil时使用自身的方言
+func (s *Selector) buildIn(dialect Dialect) (string, []interface{}, error) {
+	if dialect == nil {
+		return s.build()
+	}
+	saved := s.dialect
+	s.dialect = dialect
+	defer func() {
+		s.dialect = saved
+	}()
+	return s.build()
+}
+
 func (u *Updater) Build() (string, []interface{}, error) {
 	var sqlStr = getStrBuilder()
 	defer putStrBuilder(sqlStr)
+	u.params = nil
 	for _, m := range updateSeq {
 		sql, err := m(u)
 		if err != nil {
@@ -92,11 +135,12 @@ func (u *Updater) Build() (string, []interface{}, error) {
 			sqlStr.WriteString(sql)
 		}
 	}
-	return sqlStr.String(), u.params, nil
+	return rebind(u.getDialect(), sqlStr.String()), u.params, nil
 }
 func (d *Deleter) Build() (string, []interface{}, error) {
 	var sqlStr = getStrBuilder()
 	defer putStrBuilder(sqlStr)
+	d.params = nil
 	for _, m := range deleteSeq {
 		sql, err := m(d)
 		if err != nil {
@@ -108,7 +152,7 @@ func (d *Deleter) Build() (string, []interface{}, error) {
 			sqlStr.WriteString(sql)
 		}
 	}
-	return sqlStr.String(), d.params, nil
+	return rebind(d.getDialect(), sqlStr.String()), d.params, nil
 }
 func (i *Inserter) Build() (string, []interface{}, error) {
 	var sqlStr = getStrBuilder()
@@ -131,5 +175,45 @@ func (i *Inserter) Build() (string, []interface{}, error) {
 			params = append(params, nil)
 		}
 	}
-	return sqlStr.String(), params, nil
+	params = append(params, i.duplicateParams...)
+	return rebind(i.getDialect(), sqlStr.String()), params, nil
+}
+
+func (c *Compound) Build() (string, []interface{}, error) {
+	query, params, err := c.build()
+	if err != nil {
+		return "", nil, err
+	}
+	return rebind(c.getDialect(), query), params, nil
+}
+
+func (c *Compound) build() (string, []interface{}, error) {
+	var sqlStr = getStrBuilder()
+	defer putStrBuilder(sqlStr)
+	c.params = nil
+	for _, m := range compoundSeq {
+		sql, err := m(c)
+		if err != nil {
+			return "", nil, err
+		} else if sql != "" {
+			if sqlStr.Len() > 0 {
+				sqlStr.WriteString(" ")
+			}
+			sqlStr.WriteString(sql)
+		}
+	}
+	return sqlStr.String(), c.params, nil
+}
+
+// buildIn 以外层语句的方言生成作为子查询的语句，dialect为nil时使用自身的方言
+func (c *Compound) buildIn(dialect Dialect) (string, []interface{}, error) {
+	if dialect == nil {
+		return c.build()
+	}
+	saved := c.dialect
+	c.dialect = dialect
+	defer func() {
+		c.dialect = saved
+	}()
+	return c.build()
 }
@@ -57,8 +57,8 @@ func TestBaseSelect_Build(t *testing.T) {
 				tail:   "FOR UPDATE",
 			},
 			out: outStruct{
-				cond:   "SELECT `tb`.`id`, `tb`.`name`, `tb`.`age` FROM `tb` WHERE `foo`=? AND `qq`=? AND `age` IN (?,?,?,?,?) AND `faith`!=? AND ((`aa`=? AND `bb`=?) OR (`cc`=? AND `dd` IN (?,?))) GROUP BY `department` HAVING `id`>? ORDER BY `age` DESC, `score` ASC LIMIT ? OFFSET ? FOR UPDATE",
-				params: []interface{}{"bar", "tt", 1, 3, 5, 7, 9, "Muslim", 11, "xswl", "234", 7, 8, 0, 0, 100},
+				cond:   "SELECT `tb`.`id`, `tb`.`name`, `tb`.`age` FROM `tb` WHERE `faith`!=? AND `age` IN (?,?,?,?,?) AND `foo`=? AND `qq`=? AND ((`aa`=? AND `bb`=?) OR (`cc`=? AND `dd` IN (?,?))) GROUP BY `department` HAVING `id`>? ORDER BY `age` DESC, `score` ASC LIMIT ? OFFSET ? FOR UPDATE",
+				params: []interface{}{"Muslim", 1, 3, 5, 7, 9, "bar", "tt", 11, "xswl", "234", 7, 8, 0, 0, 100},
 				err:    nil,
 			},
 		},
@@ -74,8 +74,8 @@ func TestBaseSelect_Build(t *testing.T) {
 				offset: -1,
 			},
 			out: outStruct{
-				cond:   "SELECT `tb`.* FROM `tb` WHERE `foo`=? AND `foo2` BETWEEN ? AND ? FOR UPDATE",
-				params: []interface{}{"bar", 1, 2},
+				cond:   "SELECT `tb`.* FROM `tb` WHERE `foo2` BETWEEN ? AND ? AND `foo`=? FOR UPDATE",
+				params: []interface{}{1, 2, "bar"},
 				err:    nil,
 			},
 		},
@@ -316,8 +316,8 @@ func TestBaseUpdate_Build(t *testing.T) {
 				},
 			},
 			out: outStruct{
-				cond:   "UPDATE `tb` SET `foo`=?, `qq`=?, `inc`=`inc`+? WHERE `foo`=? AND `qq`=? AND `age` IN (?,?,?,?,?) AND `faith`!=? AND ((`aa`=? AND `bb`=?) OR (`cc`=? AND `dd` IN (?,?)))",
-				params: []interface{}{"foo2", "qq2", 2, "bar", "tt", 1, 3, 5, 7, 9, "Muslim", 11, "xswl", "234", 7, 8},
+				cond:   "UPDATE `tb` SET `inc`=`inc`+?, `foo`=?, `qq`=? WHERE `faith`!=? AND `age` IN (?,?,?,?,?) AND `foo`=? AND `qq`=? AND ((`aa`=? AND `bb`=?) OR (`cc`=? AND `dd` IN (?,?)))",
+				params: []interface{}{2, "foo2", "qq2", "Muslim", 1, 3, 5, 7, 9, "bar", "tt", 11, "xswl", "234", 7, 8},
 				err:    nil,
 			},
 		},
@@ -422,8 +422,8 @@ func TestBaseDelete_Build(t *testing.T) {
 				},
 			},
 			out: outStruct{
-				cond:   "DELETE FROM `tb` WHERE `foo`=? AND `qq`=? AND `age` IN (?,?,?,?,?) AND `faith`!=? AND ((`aa`=? AND `bb`=?) OR (`cc`=? AND `dd` IN (?,?)))",
-				params: []interface{}{"bar", "tt", 1, 3, 5, 7, 9, "Muslim", 11, "xswl", "234", 7, 8},
+				cond:   "DELETE FROM `tb` WHERE `faith`!=? AND `age` IN (?,?,?,?,?) AND `foo`=? AND `qq`=? AND ((`aa`=? AND `bb`=?) OR (`cc`=? AND `dd` IN (?,?)))",
+				params: []interface{}{"Muslim", 1, 3, 5, 7, 9, "bar", "tt", 11, "xswl", "234", 7, 8},
 				err:    nil,
 			},
 		},
@@ -443,3 +443,518 @@ func TestBaseDelete_Build(t *testing.T) {
 		ass.Equal(tc.out.params, params)
 	}
 }
+
+func TestDialect_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Select().Dialect(PostgreSQL).Table("tb").
+		Columns("id", "name").
+		FuncColumns(map[string]string{"note": "CONCAT(`name`, '?')"}).
+		Where(Clause("foo", "bar").And("age", 1, 2)).
+		Order("id DESC").
+		Limit(10).
+		Offset(20).
+		Build()
+	ass.Nil(err)
+	ass.Equal(`SELECT "tb"."id", "tb"."name", CONCAT("name", '?') AS "note" FROM "tb" WHERE "foo"=$1 AND "age" IN ($2,$3) ORDER BY "id" DESC LIMIT $4 OFFSET $5`, cond)
+	ass.Equal([]interface{}{"bar", 1, 2, 10, 20}, params)
+
+	cond, params, err = Select().Dialect(SQLite).Table("tb").Where(Clause("foo", "bar")).Offset(20).Build()
+	ass.Nil(err)
+	ass.Equal(`SELECT "tb".* FROM "tb" WHERE "foo"=? LIMIT -1 OFFSET ?`, cond)
+	ass.Equal([]interface{}{"bar", 20}, params)
+
+	cond, params, err = Select().Table("tb").Where(Clause("foo", "bar")).Offset(20).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `tb`.* FROM `tb` WHERE `foo`=? LIMIT 18446744073709551615 OFFSET ?", cond)
+	ass.Equal([]interface{}{"bar", 20}, params)
+
+	_, _, err = Select().Dialect(PostgreSQL).Table("tb").ForceIndex("idx").Build()
+	ass.Equal(ErrNotSupportDialect, err)
+
+	cond, params, err = Insert().Dialect(PostgreSQL).Table("tb").Values(map[string]interface{}{"name": "foo"}).Returning("id").Build()
+	ass.Nil(err)
+	ass.Equal(`INSERT INTO "tb"("name") VALUES($1) RETURNING "id"`, cond)
+	ass.Equal([]interface{}{"foo"}, params)
+
+	cond, params, err = Update().Dialect(PostgreSQL).Table("tb").Set(map[string]interface{}{"name": "foo"}).Where(Clause("id", 1)).Returning("id", "name").Build()
+	ass.Nil(err)
+	ass.Equal(`UPDATE "tb" SET "name"=$1 WHERE "id"=$2 RETURNING "id", "name"`, cond)
+	ass.Equal([]interface{}{"foo", 1}, params)
+
+	cond, params, err = Delete().Dialect(SQLite).Table("tb").Where(Clause("id", 1)).Returning("id").Build()
+	ass.Nil(err)
+	ass.Equal(`DELETE FROM "tb" WHERE "id"=? RETURNING "id"`, cond)
+	ass.Equal([]interface{}{1}, params)
+
+	_, _, err = Delete().Table("tb").Where(Clause("id", 1)).Returning("id").Build()
+	ass.Equal(ErrNotSupportDialect, err)
+}
+
+func TestInsertConflict_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Insert().Table("tb").Values(map[string]interface{}{"name": "foo"}).
+		OnDuplicateKeyUpdate("name").
+		OnDuplicateKeyUpdateExpr("`count`=`count`+?", 1).
+		Build()
+	ass.Nil(err)
+	ass.Equal("INSERT INTO `tb`(`name`) VALUES(?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`), `count`=`count`+?", cond)
+	ass.Equal([]interface{}{"foo", 1}, params)
+
+	cond, params, err = Insert().Dialect(PostgreSQL).Table("tb").Values(map[string]interface{}{"name": "foo"}).
+		OnConflict("id").
+		OnDuplicateKeyUpdate("name").
+		Returning("id").
+		Build()
+	ass.Nil(err)
+	ass.Equal(`INSERT INTO "tb"("name") VALUES($1) ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name" RETURNING "id"`, cond)
+	ass.Equal([]interface{}{"foo"}, params)
+
+	cond, _, err = Insert().Table("tb").Values(map[string]interface{}{"name": "foo"}).Ignore().Build()
+	ass.Nil(err)
+	ass.Equal("INSERT IGNORE INTO `tb`(`name`) VALUES(?)", cond)
+
+	cond, _, err = Insert().Dialect(SQLite).Table("tb").Values(map[string]interface{}{"name": "foo"}).Ignore().Build()
+	ass.Nil(err)
+	ass.Equal(`INSERT INTO "tb"("name") VALUES(?) ON CONFLICT DO NOTHING`, cond)
+
+	cond, _, err = Insert().Table("tb").Values(map[string]interface{}{"name": "foo"}).Replace().Build()
+	ass.Nil(err)
+	ass.Equal("REPLACE INTO `tb`(`name`) VALUES(?)", cond)
+
+	_, _, err = Insert().Dialect(PostgreSQL).Table("tb").Values(map[string]interface{}{"name": "foo"}).Replace().Build()
+	ass.Equal(ErrNotSupportDialect, err)
+
+	_, _, err = Insert().Dialect(PostgreSQL).Table("tb").Values(map[string]interface{}{"name": "foo"}).OnDuplicateKeyUpdate("name").Build()
+	ass.Equal(ErrNotSupportDialect, err)
+
+	_, _, err = Insert().Table("tb").Values(map[string]interface{}{"name": "foo"}).Ignore().OnDuplicateKeyUpdate("name").Build()
+	ass.Equal(ErrProcessInsert, err)
+}
+
+func TestModifyLimit_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Update().Table("tb").Set(map[string]interface{}{"name": "foo"}).
+		Where(Clause("status", 0)).
+		Order("id ASC").
+		Limit(100).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` SET `name`=? WHERE `status`=? ORDER BY `id` ASC LIMIT ?", cond)
+	ass.Equal([]interface{}{"foo", 0, 100}, params)
+
+	cond, params, err = Delete().Table("tb").Where(Clause("status", 0)).Order("id").Limit(1000).Build()
+	ass.Nil(err)
+	ass.Equal("DELETE FROM `tb` WHERE `status`=? ORDER BY `id` ASC LIMIT ?", cond)
+	ass.Equal([]interface{}{0, 1000}, params)
+
+	cond, params, err = Delete().Table("tb t1").
+		LeftJoin("tb2 t2", []string{"t1.id", "t2.tid"}).
+		Where(Clause("t2.id", nil)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("DELETE `t1` FROM `tb` AS `t1` LEFT JOIN `tb2` AS `t2` ON `t1`.`id`=`t2`.`tid` WHERE `t2`.`id` IS NULL", cond)
+
+	cond, _, err = Delete().Table("tb t1").
+		InnerJoin("tb2 t2", []string{"t1.id", "t2.tid"}).
+		Targets("t1", "t2").
+		Build()
+	ass.Nil(err)
+	ass.Equal("DELETE `t1`, `t2` FROM `tb` AS `t1` INNER JOIN `tb2` AS `t2` ON `t1`.`id`=`t2`.`tid`", cond)
+
+	_, _, err = Delete().Table("tb t1").InnerJoin("tb2 t2", []string{"t1.id", "t2.tid"}).Limit(10).Build()
+	ass.Equal(ErrNotSupportProcess, err)
+
+	_, _, err = Delete().Dialect(PostgreSQL).Table("tb").Limit(10).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+}
+
+func TestSubQuery_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	sub := Select().Table("order").Columns("uid").Where(Clause("amount >= ?", 100)).Limit(10)
+	cond, params, err := Select().Table("user").
+		Columns("id").
+		Where(Clause("status", 1).In("id", sub)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `user`.`id` FROM `user` WHERE `status`=? AND `id` IN (SELECT `order`.`uid` FROM `order` WHERE amount >= ? LIMIT ?)", cond)
+	ass.Equal([]interface{}{1, 100, 10}, params)
+
+	// 子查询多次构建时参数不重复
+	cond, params, err = Select().Dialect(PostgreSQL).Table("user").
+		Columns("id").
+		SubColumns(Select().Table("order").FuncColumns(map[string]string{"c": "COUNT(*)"}).Where(Clause("`order`.`uid`=`user`.`id` AND `state`=?", 2)).As("orders")).
+		Where(EmptyClause().Exists(sub).EqualTo("level", Select().Table("level").Columns("id").Where(Clause("name", "vip")))).
+		Build()
+	ass.Nil(err)
+	ass.Equal(`SELECT "user"."id", (SELECT COUNT(*) AS "c" FROM "order" WHERE "order"."uid"="user"."id" AND "state"=$1) AS "orders" FROM "user" WHERE EXISTS(SELECT "order"."uid" FROM "order" WHERE amount >= $2 LIMIT $3) AND "level"=(SELECT "level"."id" FROM "level" WHERE "name"=$4)`, cond)
+	ass.Equal([]interface{}{2, 100, 10, "vip"}, params)
+
+	cond, params, err = Select().Table(Select().Table("order").Columns("uid").Where(Clause("amount", 1)).As("o")).
+		InnerJoin(Select().Table("user").Columns("id").Where(Clause("status", 2)).As("u"), []string{"o.uid", "u.id"}).
+		Where(Clause("!o.uid", 3)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `o`.* FROM (SELECT `order`.`uid` FROM `order` WHERE `amount`=?) AS `o` INNER JOIN (SELECT `user`.`id` FROM `user` WHERE `status`=?) AS `u` ON `o`.`uid`=`u`.`id` WHERE `o`.`uid`!=?", cond)
+	ass.Equal([]interface{}{1, 2, 3}, params)
+
+	cond, params, err = Update().Table("user").
+		Set(map[string]interface{}{"score": Select().Table("order").FuncColumns(map[string]string{"s": "SUM(`amount`)"}).Where(Clause("uid", 1))}).
+		Where(Clause("id", Select().Table("tmp").Columns("uid").Where(Clause("k", "v")))).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `user` SET `score`=(SELECT SUM(`amount`) AS `s` FROM `order` WHERE `uid`=?) WHERE `id`=(SELECT `tmp`.`uid` FROM `tmp` WHERE `k`=?)", cond)
+	ass.Equal([]interface{}{1, "v"}, params)
+}
+
+func TestSubQuery_Dialect(t *testing.T) {
+	ass := assert.New(t)
+
+	// 子查询以外层语句的方言生成，外层的方言可在As之后设置
+	derived := Select().Table("order").Columns("uid").Offset(5).As("o")
+	cond, params, err := Select().Table(derived).
+		InnerJoin(Select().Table("user").Columns("id").Offset(1).As("u"), []string{"o.uid", "u.id"}).
+		SubColumns(Select().Table("level").Columns("name").Offset(2).As("lv")).
+		Where(EmptyClause().In("o.uid", Select().Table("vip").Columns("uid").Offset(3))).
+		Dialect(PostgreSQL).
+		Build()
+	ass.Nil(err)
+	ass.Equal(`SELECT (SELECT "level"."name" FROM "level" OFFSET $1) AS "lv" FROM (SELECT "order"."uid" FROM "order" OFFSET $2) AS "o" `+
+		`INNER JOIN (SELECT "user"."id" FROM "user" OFFSET $3) AS "u" ON "o"."uid"="u"."id" WHERE "o"."uid" IN (SELECT "vip"."uid" FROM "vip" OFFSET $4)`, cond)
+	ass.Equal([]interface{}{2, 5, 1, 3}, params)
+
+	// 同一子查询在MySQL中生成
+	cond, _, err = Select().Table(derived).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `o`.* FROM (SELECT `order`.`uid` FROM `order` LIMIT 18446744073709551615 OFFSET ?) AS `o`", cond)
+
+	// 锁定读的检查使用外层的方言
+	_, _, err = Select().Dialect(SQLite).Table(Select().Table("tb").Lock(LockForUpdate).As("t")).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+	_, _, err = Select().Dialect(SQLite).Table("tb").Where(EmptyClause().Exists(Select().Table("tb2").Lock(LockForShare))).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+
+	cond, _, err = Update().Dialect(PostgreSQL).Table("user").
+		Set(map[string]interface{}{"score": Select().Table("order").Columns("amount").Offset(1)}).
+		Build()
+	ass.Nil(err)
+	ass.Equal(`UPDATE "user" SET "score"=(SELECT "order"."amount" FROM "order" OFFSET $1)`, cond)
+}
+
+func TestCompound_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := UnionAll(
+		Select().Table("order_2023").Columns("id", "amount").Where(Clause("uid", 1)),
+		Select().Table("order_2024").Columns("id", "amount").Where(Clause("uid", 1)),
+	).Order("amount DESC").Limit(10).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `order_2023`.`id`, `order_2023`.`amount` FROM `order_2023` WHERE `uid`=? UNION ALL SELECT `order_2024`.`id`, `order_2024`.`amount` FROM `order_2024` WHERE `uid`=? ORDER BY `amount` DESC LIMIT ?", cond)
+	ass.Equal([]interface{}{1, 1, 10}, params)
+
+	cond, params, err = Union(Select().Table("a").Columns("id").Where(Clause("x", 1))).
+		Dialect(PostgreSQL).
+		Except(Select().Table("b").Columns("id").Where(Clause("y", 2)).Order("id").Limit(3)).
+		Offset(5).
+		Build()
+	ass.Nil(err)
+	ass.Equal(`SELECT "a"."id" FROM "a" WHERE "x"=$1 EXCEPT (SELECT "b"."id" FROM "b" WHERE "y"=$2 ORDER BY "id" ASC LIMIT $3) OFFSET $4`, cond)
+	ass.Equal([]interface{}{1, 2, 3, 5}, params)
+
+	cond, _, err = Union(Select().Table("a").Columns("id"), Select().Table("b").Columns("id")).Dialect(SQLite).Build()
+	ass.Nil(err)
+	ass.Equal(`SELECT "a"."id" FROM "a" UNION SELECT "b"."id" FROM "b"`, cond)
+
+	_, _, err = Union(Select().Table("a")).Intersect(Select().Table("b")).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+
+	_, _, err = Union(Select().Table("a"), Select().Table("b").Limit(1)).Dialect(SQLite).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+
+	_, _, err = Union(Select().Table("a")).Build()
+	ass.Equal(ErrProcessCompound, err)
+}
+
+func TestCompound_Dialect(t *testing.T) {
+	ass := assert.New(t)
+
+	// 组合的查询以外层语句的方言生成
+	cond, params, err := Union(Select().Table("a").Offset(5), Select().Table("b")).Dialect(PostgreSQL).Build()
+	ass.Nil(err)
+	ass.Equal(`(SELECT "a".* FROM "a" OFFSET $1) UNION SELECT "b".* FROM "b"`, cond)
+	ass.Equal([]interface{}{5}, params)
+
+	_, _, err = Union(Select().Table("a").Lock(LockForUpdate), Select().Table("b")).Dialect(SQLite).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+}
+
+func TestWith_Dialect(t *testing.T) {
+	ass := assert.New(t)
+
+	// 公用表表达式以外层语句的方言生成
+	cond, params, err := Select().Dialect(PostgreSQL).
+		With("recent", Union(Select().Table("a").Columns("id").Offset(1), Select().Table("b").Columns("id"))).
+		With("top", Select().Table("c").Columns("id").Offset(2)).
+		Table("recent").
+		Build()
+	ass.Nil(err)
+	ass.Equal(`WITH "recent" AS ((SELECT "a"."id" FROM "a" OFFSET $1) UNION SELECT "b"."id" FROM "b"), "top" AS (SELECT "c"."id" FROM "c" OFFSET $2) SELECT "recent".* FROM "recent"`, cond)
+	ass.Equal([]interface{}{1, 2}, params)
+
+	cond, _, err = Delete().Dialect(PostgreSQL).
+		With("old", Select().Table("log").Columns("id").Order("id").Offset(10)).
+		Table("log").
+		Where(EmptyClause().In("id", Select().Table("old").Columns("id"))).
+		Build()
+	ass.Nil(err)
+	ass.Equal(`WITH "old" AS (SELECT "log"."id" FROM "log" ORDER BY "id" ASC OFFSET $1) DELETE FROM "log" WHERE "id" IN (SELECT "old"."id" FROM "old")`, cond)
+}
+
+func TestWith_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	tree := UnionAll(
+		Select().Table("category").Columns("id", "parent_id").Where(Clause("id", 1)),
+		Select().Table("category c").Columns("c.id", "c.parent_id").InnerJoin("tree t", []string{"c.parent_id", "t.id"}),
+	)
+	cond, params, err := Select().
+		WithRecursive("tree(id, parent_id)", tree).
+		With("hot", Select().Table("stat").Columns("cid").Where(Clause("views >= ?", 100))).
+		Table("tree").
+		Columns("id").
+		Where(Clause("id", Select().Table("hot").Columns("cid")).And("parent_id", 2)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("WITH RECURSIVE `tree`(`id`, `parent_id`) AS (SELECT `category`.`id`, `category`.`parent_id` FROM `category` WHERE `id`=? UNION ALL SELECT `c`.`id`, `c`.`parent_id` FROM `category` AS `c` INNER JOIN `tree` AS `t` ON `c`.`parent_id`=`t`.`id`), `hot` AS (SELECT `stat`.`cid` FROM `stat` WHERE views >= ?) SELECT `tree`.`id` FROM `tree` WHERE `id`=(SELECT `hot`.`cid` FROM `hot`) AND `parent_id`=?", cond)
+	ass.Equal([]interface{}{1, 100, 2}, params)
+
+	cond, params, err = Update().Dialect(PostgreSQL).
+		With("expired", Select().Table("session").Columns("uid").Where(Clause("ttl", 0))).
+		Table("user").
+		Set(map[string]interface{}{"online": false}).
+		Where(Clause("id", Select().Table("expired").Columns("uid"))).
+		Build()
+	ass.Nil(err)
+	ass.Equal(`WITH "expired" AS (SELECT "session"."uid" FROM "session" WHERE "ttl"=$1) UPDATE "user" SET "online"=$2 WHERE "id"=(SELECT "expired"."uid" FROM "expired")`, cond)
+	ass.Equal([]interface{}{0, false}, params)
+
+	cond, params, err = Delete().
+		With("old", Select().Table("log").Columns("id").Where(Clause("day < ?", 30))).
+		Table("log").
+		Where(EmptyClause().In("id", Select().Table("old").Columns("id"))).
+		Build()
+	ass.Nil(err)
+	ass.Equal("WITH `old` AS (SELECT `log`.`id` FROM `log` WHERE day < ?) DELETE FROM `log` WHERE `id` IN (SELECT `old`.`id` FROM `old`)", cond)
+	ass.Equal([]interface{}{30}, params)
+}
+
+func TestExprColumns_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Select().Table("order").
+		Columns("uid").
+		ExprColumns(
+			Count("*").As("cnt"),
+			Sum(Col("price").Mul(Col("qty")).Sub(5)).As("total"),
+			RowNumber().Over(Window().PartitionBy("uid").OrderBy("created DESC")).As("rn"),
+			Sum("amount").Over(Window().OrderBy("id")).As("running"),
+			Case().When(Clause("status", 1), "paid").When("`status` = 2", "refund").Else("other").End().As("state"),
+			Case(Col("level")).When(1, Col("price")).Else(Col("price").Mul(0.8)).End().As("discount"),
+			Expr("DATE_FORMAT(`created`, ?)", "%Y-%m").As("month"),
+		).
+		Where(Clause("uid", 7)).
+		Group("uid").
+		Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `order`.`uid`, COUNT(*) AS `cnt`, SUM((`price`*`qty`)-?) AS `total`, ROW_NUMBER() OVER (PARTITION BY `uid` ORDER BY `created` DESC) AS `rn`, "+
+		"SUM(`amount`) OVER (ORDER BY `id` ASC) AS `running`, CASE WHEN `status`=? THEN ? WHEN `status` = 2 THEN ? ELSE ? END AS `state`, "+
+		"CASE `level` WHEN ? THEN `price` ELSE `price`*? END AS `discount`, DATE_FORMAT(`created`, ?) AS `month` FROM `order` WHERE `uid`=? GROUP BY `uid`", cond)
+	ass.Equal([]interface{}{5, 1, "paid", "refund", "other", 1, 0.8, "%Y-%m", 7}, params)
+
+	cond, _, err = Select().Table("order").ExprColumns(CountDistinct("uid").As("users")).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT COUNT(DISTINCT `uid`) AS `users` FROM `order`", cond)
+
+	_, _, err = Select().Table("order").ExprColumns(Expr("IFNULL(`a`, ?)")).Build()
+	ass.Equal(ErrBuildPlaceHolder, err)
+
+	// As不修改原表达式
+	total := Sum("amount")
+	cond, _, err = Select().Table("order").ExprColumns(total.As("a"), total.As("b"), total).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT SUM(`amount`) AS `a`, SUM(`amount`) AS `b`, SUM(`amount`) FROM `order`", cond)
+
+	// 参数构建失败时返回错误而不是panic
+	_, _, err = Select().Table("order").ExprColumns(CountDistinct(Expr("IFNULL(`a`, ?)")).As("cnt")).Build()
+	ass.Equal(ErrBuildPlaceHolder, err)
+}
+
+func TestLock_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Select().Table("tb").Where(Clause("status", 0)).Limit(10).Lock(LockForUpdateSkipLocked).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `tb`.* FROM `tb` WHERE `status`=? LIMIT ? FOR UPDATE SKIP LOCKED", cond)
+	ass.Equal([]interface{}{0, 10}, params)
+
+	cond, _, err = Select().Dialect(PostgreSQL).Table("tb").Where(Clause("id", 1)).Lock(LockForShare).Build()
+	ass.Nil(err)
+	ass.Equal(`SELECT "tb".* FROM "tb" WHERE "id"=$1 FOR SHARE`, cond)
+
+	cond, _, err = Select().Table("tb").Where(Clause("id", 1)).Lock(LockForUpdateNoWait).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `tb`.* FROM `tb` WHERE `id`=? FOR UPDATE NOWAIT", cond)
+
+	_, _, err = Select().Dialect(SQLite).Table("tb").Lock(LockForUpdate).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+}
+
+func TestIndexHint_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, _, err := Select().Table("tb").UseIndex("idx_a", "idx_b").Where(Clause("id", 1)).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `tb`.* FROM `tb` USE INDEX (`idx_a`, `idx_b`) WHERE `id`=?", cond)
+
+	cond, _, err = Select().Table("tb t1").
+		IndexHint(ForceIndex("idx_status").For(ForJoin), IgnoreIndex("idx_created").For(ForOrderBy)).
+		InnerJoin("tb2 t2", []string{"t1.id", "t2.tid"}).
+		JoinIndexHint("t2", UseIndex("idx_tid")).
+		Order("t1.created").
+		Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `t1`.* FROM `tb` AS `t1` FORCE INDEX FOR JOIN (`idx_status`) IGNORE INDEX FOR ORDER BY (`idx_created`) "+
+		"INNER JOIN `tb2` AS `t2` USE INDEX (`idx_tid`) ON `t1`.`id`=`t2`.`tid` ORDER BY `t1`.`created` ASC", cond)
+
+	cond, _, err = Select().Table("tb").ForceIndex("").Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `tb`.* FROM `tb`", cond)
+
+	_, _, err = Select().Table("tb t1").InnerJoin("tb2 t2", []string{"t1.id", "t2.tid"}).JoinIndexHint("tb3", UseIndex("idx")).Build()
+	ass.Equal(ErrProcessIndexHint, err)
+
+	_, _, err = Select().Dialect(PostgreSQL).Table("tb t1").InnerJoin("tb2 t2", []string{"t1.id", "t2.tid"}).JoinIndexHint("t2", UseIndex("idx")).Build()
+	ass.Equal(ErrNotSupportDialect, err)
+}
+
+func TestJoinClause_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Select().Table("tb t1").
+		Columns("t1.id").
+		LeftJoin("tb2 t2", Clause("t1.id", Col("t2.tid")).And("t2.deleted", 0).And("t2.type = ?", 3)).
+		Where(Clause("t1.status", 1)).
+		Limit(10).
+		Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `t1`.`id` FROM `tb` AS `t1` LEFT JOIN `tb2` AS `t2` ON `t1`.`id`=`t2`.`tid` AND `t2`.`deleted`=? AND t2.type = ? WHERE `t1`.`status`=? LIMIT ?", cond)
+	ass.Equal([]interface{}{0, 3, 1, 10}, params)
+
+	cond, params, err = Select().Table("tb t1").
+		InnerJoin("tb2 t2", map[string]interface{}{"t1.id": Col("t2.tid")}).
+		InnerJoin("tb3 t3", []string{"t2.id", "t3.tid"}).
+		Where(Clause("t3.name", "foo")).
+		Build()
+	ass.Nil(err)
+	ass.Equal("SELECT `t1`.* FROM `tb` AS `t1` INNER JOIN `tb2` AS `t2` ON `t1`.`id`=`t2`.`tid` INNER JOIN `tb3` AS `t3` ON `t2`.`id`=`t3`.`tid` WHERE `t3`.`name`=?", cond)
+	ass.Equal([]interface{}{"foo"}, params)
+
+	cond, params, err = Update().Table("tb t1").
+		InnerJoin("tb2 t2", Clause("t1.id", Col("t2.tid")).And("t2.type", 2)).
+		Set(map[string]interface{}{"t1.name": "foo"}).
+		Where(Clause("t1.id", 1)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` AS `t1` INNER JOIN `tb2` AS `t2` ON `t1`.`id`=`t2`.`tid` AND `t2`.`type`=? SET `t1`.`name`=? WHERE `t1`.`id`=?", cond)
+	ass.Equal([]interface{}{2, "foo", 1}, params)
+
+	_, _, err = Select().Table("tb t1").InnerJoin("tb2 t2", Clause("t1.id", Expr("?"))).Build()
+	ass.Equal(ErrBuildPlaceHolder, err)
+}
+
+func TestOrdered_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Update().Table("tb").
+		Set(map[string]interface{}{"name": "foo", "age": 1, "score": 2}).
+		Where(map[string]interface{}{"status": 0, "id": 1}).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` SET `age`=?, `name`=?, `score`=? WHERE `id`=? AND `status`=?", cond)
+	ass.Equal([]interface{}{1, "foo", 2, 1, 0}, params)
+
+	cond, params, err = Update().Table("tb").
+		SetOrdered(NewOrderedMap().Set("name", "foo").Set("age", 1)).
+		Where(NewOrderedMap().Set("status", 0).Set("id", 1)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` SET `name`=?, `age`=? WHERE `status`=? AND `id`=?", cond)
+	ass.Equal([]interface{}{"foo", 1, 0, 1}, params)
+
+	row := map[string]interface{}{"name": "foo", "age": 1}
+	cond, params, err = Insert().Table("tb").Values(row, map[string]interface{}{"name": "bar", "score": 2}).Build()
+	ass.Nil(err)
+	ass.Equal("INSERT INTO `tb`(`age`, `name`, `score`) VALUES(?,?,?), (?,?,?)", cond)
+	ass.Equal([]interface{}{1, "foo", nil, nil, "bar", 2}, params)
+	ass.Len(row, 2)
+
+	cond, params, err = Insert().Table("tb").ValuesOrdered(NewOrderedMap().Set("name", "foo").Set("age", 1)).Build()
+	ass.Nil(err)
+	ass.Equal("INSERT INTO `tb`(`name`, `age`) VALUES(?,?)", cond)
+	ass.Equal([]interface{}{"foo", 1}, params)
+
+	cond, _, err = Select().Table("tb").FuncColumns(map[string]string{"total": "COUNT(*)", "avg": "AVG(`age`)"}).Build()
+	ass.Nil(err)
+	ass.Equal("SELECT AVG(`age`) AS `avg`, COUNT(*) AS `total` FROM `tb`", cond)
+}
+
+func TestSetExpr_Build(t *testing.T) {
+	ass := assert.New(t)
+
+	cond, params, err := Update().Table("tb").
+		Set(map[string]interface{}{"name": "foo"}).
+		Incr("views", 1).
+		Decr("stock", 2).
+		SetExpr("balance", "`balance`*?", 1.1).
+		SetNull("deleted_at").
+		Where(Clause("id", 1)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` SET `name`=?, `views`=`views`+?, `stock`=`stock`-?, `balance`=`balance`*?, `deleted_at`=NULL WHERE `id`=?", cond)
+	ass.Equal([]interface{}{"foo", 1, 2, 1.1, 1}, params)
+
+	cond, params, err = Update().Table("tb").
+		SetCase("status", Case("id").When(1, 10).When(2, 20).Else(Col("status"))).
+		Where(Clause("id", 1, 2)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` SET `status`=CASE `id` WHEN ? THEN ? WHEN ? THEN ? ELSE `status` END WHERE `id` IN (?,?)", cond)
+	ass.Equal([]interface{}{1, 10, 2, 20, 1, 2}, params)
+
+	// Set与Incr等合并，不丢弃之前设置的列
+	cond, params, err = Update().Table("tb").
+		Incr("views", 1).
+		SetNull("deleted_at").
+		Set(map[string]interface{}{"name": "foo", "stock": 3}).
+		Where(Clause("id", 1)).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` SET `views`=`views`+?, `deleted_at`=NULL, `name`=?, `stock`=? WHERE `id`=?", cond)
+	ass.Equal([]interface{}{1, "foo", 3, 1}, params)
+
+	// 同名的列以后设置的为准，位置不变
+	cond, params, err = Update().Table("tb").
+		Set(map[string]interface{}{"name": "foo", "views": 0}).
+		Incr("views", 2).
+		SetOrdered(NewOrderedMap().Set("name", "bar")).
+		Build()
+	ass.Nil(err)
+	ass.Equal("UPDATE `tb` SET `name`=?, `views`=`views`+?", cond)
+	ass.Equal([]interface{}{"bar", 2}, params)
+
+	_, _, err = Update().Table("tb").SetExpr("balance", "`balance`*?").Build()
+	ass.Equal(ErrBuildPlaceHolder, err)
+}
@@ -47,38 +47,15 @@ func buildPredicate(where interface{}, values ...interface{}) *Predicate {
 		}
 		wherePredicate.AddPredicate(predicateIfe, combination)
 	case map[string]interface{}:
-		for key, value := range w {
-			var predicateIfe PredicateIfe
-			if strings.Contains(key, PlaceHolder) {
-				if v, ok := value.([]interface{}); ok {
-					if strings.Count(key, PlaceHolder) != len(v) {
-						predicateIfe = ErrExpression(ErrBuildPlaceHolder)
-					} else {
-						predicateIfe = NewExpression(key, v...)
-					}
-				} else {
-					predicateIfe = NewExpression(key, value)
-				}
-			} else if value == nil {
-				if strings.Index(key, "!") == 0 {
-					predicateIfe = isNotNull(key[1:])
-				} else {
-					predicateIfe = isNull(key)
-				}
-			} else if v, ok := value.([]interface{}); ok {
-				if strings.Index(key, "!") == 0 {
-					predicateIfe = notIn(key[1:], v...)
-				} else {
-					predicateIfe = in(key, v...)
-				}
-			} else {
-				if strings.Index(key, "!") == 0 {
-					predicateIfe = operate(key[1:], OpNe, value)
-				} else {
-					predicateIfe = operate(key, OpEq, value)
-				}
+		for _, key := range sortedKeys(w) {
+			wherePredicate.AddPredicate(mapPredicate(key, w[key]), combination)
+		}
+	case *OrderedMap:
+		if w != nil {
+			for _, key := range w.Keys() {
+				value, _ := w.Get(key)
+				wherePredicate.AddPredicate(mapPredicate(key, value), combination)
 			}
-			wherePredicate.AddPredicate(predicateIfe, combination)
 		}
 	case PredicateIfe:
 		if w != nil {
@@ -160,12 +137,12 @@ func (c *clause) NotBetween(identifier string, minValue, maxValue interface{}) *
 	return c
 }
 
-func (c *clause) Exists(specification string, values ...interface{}) *clause {
+func (c *clause) Exists(specification interface{}, values ...interface{}) *clause {
 	c.predicate.AddPredicate(exists(specification, values...), defaultCombination)
 	return c
 }
 
-func (c *clause) NotExists(specification string, values ...interface{}) *clause {
+func (c *clause) NotExists(specification interface{}, values ...interface{}) *clause {
 	c.predicate.AddPredicate(notExists(specification, values...), defaultCombination)
 	return c
 }
@@ -189,3 +166,30 @@ func (c *clause) IsNotNull(identifier string) *clause {
 	c.predicate.AddPredicate(isNotNull(identifier), defaultCombination)
 	return c
 }
+
+// mapPredicate 将map中的一组键值转为条件，键以!开头表示取反，包含?的键作为表达式
+func mapPredicate(key string, value interface{}) PredicateIfe {
+	if strings.Contains(key, PlaceHolder) {
+		if v, ok := value.([]interface{}); ok {
+			if strings.Count(key, PlaceHolder) != len(v) {
+				return ErrExpression(ErrBuildPlaceHolder)
+			}
+			return NewExpression(key, v...)
+		}
+		return NewExpression(key, value)
+	} else if value == nil {
+		if strings.Index(key, "!") == 0 {
+			return isNotNull(key[1:])
+		}
+		return isNull(key)
+	} else if v, ok := value.([]interface{}); ok {
+		if strings.Index(key, "!") == 0 {
+			return notIn(key[1:], v...)
+		}
+		return in(key, v...)
+	}
+	if strings.Index(key, "!") == 0 {
+		return operate(key[1:], OpNe, value)
+	}
+	return operate(key, OpEq, value)
+}
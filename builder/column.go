@@ -0,0 +1,312 @@
+package builder
+
+import (
+	"strings"
+)
+
+// Column 带参数的表达式列，按声明顺序输出
+type Column struct {
+	expr   string
+	params []interface{}
+	alias  string
+	atomic bool // 作为运算数时是否无需括号包裹
+	err    error
+}
+
+// Col 以列名作为表达式
+func Col(name string) *Column {
+	return &Column{expr: QuoteIdentifier(name), atomic: true}
+}
+
+// Expr 以原始SQL作为表达式，占位符数量需与参数一致
+func Expr(expr string, params ...interface{}) *Column {
+	if strings.Count(expr, PlaceHolder) != len(params) {
+		return &Column{err: ErrBuildPlaceHolder}
+	}
+	return &Column{expr: expr, params: params}
+}
+
+// As 返回带别名的副本，同一表达式可以不同的别名多次使用
+func (c *Column) As(alias string) *Column {
+	aliased := *c
+	aliased.alias = alias
+	return &aliased
+}
+
+// operand 生成运算数，identifier为true时字符串视为列名，否则作为参数
+func operand(v interface{}, identifier bool) (string, []interface{}, error) {
+	switch o := v.(type) {
+	case *Column:
+		return o.expr, o.params, o.err
+	case *Selector:
+		sql, params, err := o.build()
+		return "(" + sql + ")", append([]interface{}{}, params...), err
+	case string:
+		if identifier {
+			return QuoteIdentifier(o), nil, nil
+		}
+	}
+	return PlaceHolder, []interface{}{v}, nil
+}
+
+// function 生成函数表达式，字符串参数视为列名
+func function(name string, args ...interface{}) *Column {
+	var (
+		str = getStrBuilder()
+		c   = &Column{atomic: true}
+	)
+	defer putStrBuilder(str)
+	str.WriteString(name)
+	str.WriteString("(")
+	for i, arg := range args {
+		if i > 0 {
+			str.WriteString(", ")
+		}
+		expr, params, err := operand(arg, true)
+		if err != nil {
+			return &Column{err: err}
+		}
+		str.WriteString(expr)
+		c.params = append(c.params, params...)
+	}
+	str.WriteString(")")
+	c.expr = str.String()
+	return c
+}
+
+// Func 以任意函数作为表达式，字符串参数视为列名，其余作为参数
+func Func(name string, args ...interface{}) *Column {
+	return function(name, args...)
+}
+
+func Count(column interface{}) *Column {
+	return function("COUNT", column)
+}
+
+func CountDistinct(column interface{}) *Column {
+	c := function("COUNT", column)
+	if c.err != nil {
+		return c
+	}
+	c.expr = "COUNT(DISTINCT " + c.expr[len("COUNT("):]
+	return c
+}
+
+func Sum(column interface{}) *Column {
+	return function("SUM", column)
+}
+
+func Avg(column interface{}) *Column {
+	return function("AVG", column)
+}
+
+func Max(column interface{}) *Column {
+	return function("MAX", column)
+}
+
+func Min(column interface{}) *Column {
+	return function("MIN", column)
+}
+
+func RowNumber() *Column {
+	return function("ROW_NUMBER")
+}
+
+func Rank() *Column {
+	return function("RANK")
+}
+
+func DenseRank() *Column {
+	return function("DENSE_RANK")
+}
+
+// arithmetic 四则运算，字符串作为参数，列名需使用Col
+func (c *Column) arithmetic(operator string, v interface{}) *Column {
+	left, leftParams, err := operand(c, false)
+	if err != nil {
+		return &Column{err: err}
+	} else if !c.atomic {
+		left = "(" + left + ")"
+	}
+	right, rightParams, err := operand(v, false)
+	if err != nil {
+		return &Column{err: err}
+	} else if o, ok := v.(*Column); ok && !o.atomic {
+		right = "(" + right + ")"
+	}
+	return &Column{
+		expr:   left + operator + right,
+		params: append(append([]interface{}{}, leftParams...), rightParams...),
+	}
+}
+
+func (c *Column) Add(v interface{}) *Column {
+	return c.arithmetic("+", v)
+}
+
+func (c *Column) Sub(v interface{}) *Column {
+	return c.arithmetic("-", v)
+}
+
+func (c *Column) Mul(v interface{}) *Column {
+	return c.arithmetic("*", v)
+}
+
+func (c *Column) Div(v interface{}) *Column {
+	return c.arithmetic("/", v)
+}
+
+// WindowSpec 窗口函数的窗口定义
+type WindowSpec struct {
+	partition []string
+	order     []string
+}
+
+func Window() *WindowSpec {
+	return &WindowSpec{}
+}
+
+func (w *WindowSpec) PartitionBy(columns ...string) *WindowSpec {
+	w.partition = columns
+	return w
+}
+
+func (w *WindowSpec) OrderBy(order ...string) *WindowSpec {
+	w.order = order
+	return w
+}
+
+// Over 以窗口函数的形式计算，如 ROW_NUMBER() OVER (PARTITION BY ... ORDER BY ...)
+func (c *Column) Over(w *WindowSpec) *Column {
+	if c.err != nil {
+		return c
+	}
+	var str = getStrBuilder()
+	defer putStrBuilder(str)
+	str.WriteString(c.expr)
+	str.WriteString(" OVER (")
+	if len(w.partition) > 0 {
+		str.WriteString("PARTITION BY ")
+		for i, p := range w.partition {
+			if i > 0 {
+				str.WriteString(", ")
+			}
+			str.WriteString(QuoteIdentifier(p))
+		}
+	}
+	order, err := processOrder(w.order)
+	if err != nil {
+		return &Column{err: err}
+	}
+	if order != "" {
+		if len(w.partition) > 0 {
+			str.WriteString(" ")
+		}
+		str.WriteString(order)
+	}
+	str.WriteString(")")
+	return &Column{expr: str.String(), params: c.params, alias: c.alias, atomic: true}
+}
+
+type caseWhen struct {
+	when interface{}
+	then interface{}
+}
+
+// CaseExpr CASE WHEN表达式，End后得到Column
+type CaseExpr struct {
+	operand  interface{}
+	hasValue bool
+	whens    []caseWhen
+	orElse   interface{}
+	hasElse  bool
+}
+
+// Case 传入operand时为简单CASE，When的条件为与operand比较的值，否则When的条件同Where
+func Case(operand ...interface{}) *CaseExpr {
+	c := &CaseExpr{}
+	if len(operand) > 0 {
+		c.operand = operand[0]
+		c.hasValue = true
+	}
+	return c
+}
+
+// When then为结果值，字符串作为参数，列名需使用Col
+func (c *CaseExpr) When(when interface{}, then interface{}) *CaseExpr {
+	c.whens = append(c.whens, caseWhen{when: when, then: then})
+	return c
+}
+
+func (c *CaseExpr) Else(v interface{}) *CaseExpr {
+	c.orElse = v
+	c.hasElse = true
+	return c
+}
+
+func (c *CaseExpr) End() *Column {
+	var (
+		str    = getStrBuilder()
+		params []interface{}
+	)
+	defer putStrBuilder(str)
+	str.WriteString("CASE")
+	write := func(keyword string, v interface{}, identifier bool) error {
+		expr, p, err := operand(v, identifier)
+		if err != nil {
+			return err
+		}
+		str.WriteString(keyword)
+		str.WriteString(expr)
+		params = append(params, p...)
+		return nil
+	}
+	if c.hasValue {
+		if err := write(" ", c.operand, true); err != nil {
+			return &Column{err: err}
+		}
+	}
+	for _, w := range c.whens {
+		if c.hasValue {
+			if err := write(" WHEN ", w.when, false); err != nil {
+				return &Column{err: err}
+			}
+		} else if raw, ok := w.when.(string); ok && !strings.Contains(raw, PlaceHolder) {
+			str.WriteString(" WHEN ")
+			str.WriteString(raw)
+		} else {
+			parts, err := buildPredicate(w.when).GetExpressionData()
+			if err != nil {
+				return &Column{err: err}
+			} else if len(parts) == 0 {
+				return &Column{err: ErrNotSupportProcess}
+			}
+			str.WriteString(" WHEN ")
+			for _, part := range parts {
+				switch p := part.(type) {
+				case string:
+					str.WriteString(p)
+				case *Expression:
+					spec, values, err := p.resolve(nil)
+					if err != nil {
+						return &Column{err: err}
+					}
+					str.WriteString(spec)
+					params = append(params, values...)
+				default:
+					return &Column{err: ErrNotSupportProcess}
+				}
+			}
+		}
+		if err := write(" THEN ", w.then, false); err != nil {
+			return &Column{err: err}
+		}
+	}
+	if c.hasElse {
+		if err := write(" ELSE ", c.orElse, false); err != nil {
+			return &Column{err: err}
+		}
+	}
+	str.WriteString(" END")
+	return &Column{expr: str.String(), params: params, atomic: true}
+}
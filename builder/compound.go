@@ -0,0 +1,95 @@
+package builder
+
+const (
+	CompoundUnion     = "UNION"
+	CompoundUnionAll  = "UNION ALL"
+	CompoundIntersect = "INTERSECT"
+	CompoundExcept    = "EXCEPT"
+)
+
+// Compound 以UNION、INTERSECT、EXCEPT组合多个查询
+type Compound struct {
+	selectors []*Selector
+	operators []string // operators[i]为selectors[i]与前一个查询的组合方式
+	order     []string
+	limit     int
+	offset    int
+	params    []interface{}
+	dialect   Dialect
+}
+
+func newCompound(operator string, selectors ...*Selector) *Compound {
+	c := &Compound{
+		limit:  -1,
+		offset: -1,
+	}
+	for _, s := range selectors {
+		c.add(operator, s)
+	}
+	return c
+}
+
+func Union(selectors ...*Selector) *Compound {
+	return newCompound(CompoundUnion, selectors...)
+}
+
+func UnionAll(selectors ...*Selector) *Compound {
+	return newCompound(CompoundUnionAll, selectors...)
+}
+
+func (c *Compound) add(operator string, s *Selector) *Compound {
+	c.selectors = append(c.selectors, s)
+	c.operators = append(c.operators, operator)
+	return c
+}
+
+func (c *Compound) addParams(params ...interface{}) {
+	if c.params == nil {
+		c.params = make([]interface{}, 0)
+	}
+	c.params = append(c.params, params...)
+}
+
+func (c *Compound) getDialect() Dialect {
+	return resolveDialect(c.dialect)
+}
+
+func (c *Compound) Dialect(dialect Dialect) *Compound {
+	c.dialect = dialect
+	return c
+}
+
+func (c *Compound) Union(s *Selector) *Compound {
+	return c.add(CompoundUnion, s)
+}
+
+func (c *Compound) UnionAll(s *Selector) *Compound {
+	return c.add(CompoundUnionAll, s)
+}
+
+func (c *Compound) Intersect(s *Selector) *Compound {
+	return c.add(CompoundIntersect, s)
+}
+
+func (c *Compound) Except(s *Selector) *Compound {
+	return c.add(CompoundExcept, s)
+}
+
+func (c *Compound) Order(order ...string) *Compound {
+	c.order = order
+	return c
+}
+
+func (c *Compound) Limit(limit int) *Compound {
+	if limit >= 0 {
+		c.limit = limit
+	}
+	return c
+}
+
+func (c *Compound) Offset(offset int) *Compound {
+	if offset >= 0 {
+		c.offset = offset
+	}
+	return c
+}
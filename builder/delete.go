@@ -1,13 +1,22 @@
 package builder
 
 type Deleter struct {
-	table  string
-	where  *Predicate
-	params []interface{}
+	table     string
+	targets   []string // 多表删除时删除记录的表
+	where     *Predicate
+	with      *with
+	join      *join
+	order     []string
+	limit     int
+	returning []string
+	params    []interface{}
+	dialect   Dialect
 }
 
 func Delete() *Deleter {
-	return &Deleter{}
+	return &Deleter{
+		limit: -1,
+	}
 }
 
 func (d *Deleter) addParams(params ...interface{}) {
@@ -17,6 +26,15 @@ func (d *Deleter) addParams(params ...interface{}) {
 	d.params = append(d.params, params...)
 }
 
+func (d *Deleter) getDialect() Dialect {
+	return resolveDialect(d.dialect)
+}
+
+func (d *Deleter) Dialect(dialect Dialect) *Deleter {
+	d.dialect = dialect
+	return d
+}
+
 func (d *Deleter) Table(table string) *Deleter {
 	d.table = table
 	return d
@@ -41,3 +59,73 @@ func (d *Deleter) Where(where interface{}) *Deleter {
 	}
 	return d
 }
+
+func (d *Deleter) Order(order ...string) *Deleter {
+	d.order = order
+	return d
+}
+
+func (d *Deleter) Limit(limit int) *Deleter {
+	if limit >= 0 {
+		d.limit = limit
+	}
+	return d
+}
+
+// Targets 设置多表删除时删除记录的表或别名，默认为主表
+func (d *Deleter) Targets(tables ...string) *Deleter {
+	d.targets = tables
+	return d
+}
+
+func (d *Deleter) InnerJoin(name interface{}, on interface{}) *Deleter {
+	if d.join == nil {
+		d.join = newJoin()
+	}
+	d.join.join(name, on, JoinInner)
+	return d
+}
+
+func (d *Deleter) LeftJoin(name interface{}, on interface{}) *Deleter {
+	if d.join == nil {
+		d.join = newJoin()
+	}
+	d.join.join(name, on, JoinLeft)
+	return d
+}
+
+func (d *Deleter) RightJoin(name interface{}, on interface{}) *Deleter {
+	if d.join == nil {
+		d.join = newJoin()
+	}
+	d.join.join(name, on, JoinRight)
+	return d
+}
+
+// HasWhere 是否设置了非空的where条件
+func (d *Deleter) HasWhere() bool {
+	return d.where != nil && d.where.count() > 0
+}
+
+func (d *Deleter) Returning(columns ...string) *Deleter {
+	d.returning = columns
+	return d
+}
+
+// With 添加公用表表达式，query为*Selector或*Compound，name可带列名，如 "tree(id, parent_id)"
+func (d *Deleter) With(name string, query interface{}) *Deleter {
+	if d.with == nil {
+		d.with = newWith()
+	}
+	d.with.add(false, name, query)
+	return d
+}
+
+// WithRecursive 添加递归公用表表达式
+func (d *Deleter) WithRecursive(name string, query interface{}) *Deleter {
+	if d.with == nil {
+		d.with = newWith()
+	}
+	d.with.add(true, name, query)
+	return d
+}
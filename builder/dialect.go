@@ -0,0 +1,244 @@
+package builder
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrNotSupportDialect = errors.New("[builder] not supported by dialect")
+
+type Feature int
+
+const (
+	FeatureReturning Feature = iota
+	FeatureIndexHint
+	FeatureUpdateJoin
+	FeatureOnDuplicateKey // INSERT ... ON DUPLICATE KEY UPDATE
+	FeatureOnConflict     // INSERT ... ON CONFLICT
+	FeatureInsertIgnore   // INSERT IGNORE
+	FeatureReplace        // REPLACE INTO
+	FeatureModifyLimit    // UPDATE、DELETE ... ORDER BY ... LIMIT
+	FeatureDeleteJoin     // DELETE t1 FROM t1 JOIN t2
+	FeatureIntersect      // INTERSECT、EXCEPT
+	FeatureCompoundParens // UNION等组合查询的子查询可用括号包裹
+	FeatureLock           // FOR UPDATE、FOR SHARE
+	FeatureLockWait       // NOWAIT、SKIP LOCKED
+)
+
+// Dialect 描述数据库方言的差异
+// builder内部统一以反引号引用标识符、以?作为占位符生成语句，Build时再由Dialect转换
+type Dialect interface {
+	Name() string
+	QuoteIdentifier(name string) string
+	PlaceHolder(index int) string
+	LimitOffset(limit, offset int) (string, []interface{})
+	Supports(feature Feature) bool
+}
+
+var (
+	MySQL      Dialect = mysqlDialect{}
+	PostgreSQL Dialect = postgresDialect{}
+	SQLite     Dialect = sqliteDialect{}
+)
+
+var (
+	defaultDialect       = MySQL
+	dialects             = map[string]Dialect{}
+	dialectsLocker       sync.RWMutex
+	defaultDialectLocker sync.RWMutex
+)
+
+func init() {
+	RegisterDialect("mysql", MySQL)
+	RegisterDialect("postgres", PostgreSQL)
+	RegisterDialect("pgx", PostgreSQL)
+	RegisterDialect("sqlite3", SQLite)
+	RegisterDialect("sqlite", SQLite)
+}
+
+// RegisterDialect 按驱动名注册方言
+func RegisterDialect(name string, dialect Dialect) {
+	dialectsLocker.Lock()
+	defer dialectsLocker.Unlock()
+	dialects[name] = dialect
+}
+
+// GetDialect 根据驱动名获取方言
+func GetDialect(name string) (Dialect, bool) {
+	dialectsLocker.RLock()
+	defer dialectsLocker.RUnlock()
+	dialect, ok := dialects[name]
+	return dialect, ok
+}
+
+func SetDefaultDialect(dialect Dialect) {
+	defaultDialectLocker.Lock()
+	defer defaultDialectLocker.Unlock()
+	if dialect != nil {
+		defaultDialect = dialect
+	}
+}
+
+func DefaultDialect() Dialect {
+	defaultDialectLocker.RLock()
+	defer defaultDialectLocker.RUnlock()
+	return defaultDialect
+}
+
+func resolveDialect(dialect Dialect) Dialect {
+	if dialect == nil {
+		return DefaultDialect()
+	}
+	return dialect
+}
+
+// rebind 将内部生成的语句转换为方言对应的形式
+// 字符串常量中的内容保持不变
+func rebind(dialect Dialect, query string) string {
+	if _, ok := dialect.(mysqlDialect); ok {
+		return query
+	}
+	var (
+		str   = getStrBuilder()
+		index = 0
+	)
+	defer putStrBuilder(str)
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '\'', '"':
+			end := i + 1
+			for end < len(query) && query[end] != c {
+				end++
+			}
+			if end >= len(query) {
+				str.WriteString(query[i:])
+				i = len(query)
+				continue
+			}
+			str.WriteString(query[i : end+1])
+			i = end
+		case '`':
+			end := strings.IndexByte(query[i+1:], '`')
+			if end < 0 {
+				str.WriteString(query[i:])
+				i = len(query)
+				continue
+			}
+			str.WriteString(dialect.QuoteIdentifier(query[i+1 : i+1+end]))
+			i += end + 1
+		case '?':
+			index++
+			str.WriteString(dialect.PlaceHolder(index))
+		default:
+			str.WriteByte(c)
+		}
+	}
+	return str.String()
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func (mysqlDialect) PlaceHolder(int) string {
+	return PlaceHolder
+}
+
+func (mysqlDialect) LimitOffset(limit, offset int) (string, []interface{}) {
+	switch {
+	case limit >= 0 && offset >= 0:
+		return "LIMIT ? OFFSET ?", []interface{}{limit, offset}
+	case limit >= 0:
+		return "LIMIT ?", []interface{}{limit}
+	case offset >= 0:
+		// mysql的OFFSET必须跟在LIMIT之后
+		return "LIMIT 18446744073709551615 OFFSET ?", []interface{}{offset}
+	}
+	return "", nil
+}
+
+func (mysqlDialect) Supports(feature Feature) bool {
+	switch feature {
+	case FeatureIndexHint, FeatureUpdateJoin, FeatureOnDuplicateKey, FeatureInsertIgnore, FeatureReplace,
+		FeatureModifyLimit, FeatureDeleteJoin, FeatureCompoundParens, FeatureLock, FeatureLockWait:
+		return true
+	}
+	return false
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (postgresDialect) PlaceHolder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+func (postgresDialect) LimitOffset(limit, offset int) (string, []interface{}) {
+	switch {
+	case limit >= 0 && offset >= 0:
+		return "LIMIT ? OFFSET ?", []interface{}{limit, offset}
+	case limit >= 0:
+		return "LIMIT ?", []interface{}{limit}
+	case offset >= 0:
+		return "OFFSET ?", []interface{}{offset}
+	}
+	return "", nil
+}
+
+func (postgresDialect) Supports(feature Feature) bool {
+	switch feature {
+	case FeatureReturning, FeatureOnConflict, FeatureIntersect, FeatureCompoundParens, FeatureLock, FeatureLockWait:
+		return true
+	}
+	return false
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (sqliteDialect) PlaceHolder(int) string {
+	return PlaceHolder
+}
+
+func (sqliteDialect) LimitOffset(limit, offset int) (string, []interface{}) {
+	switch {
+	case limit >= 0 && offset >= 0:
+		return "LIMIT ? OFFSET ?", []interface{}{limit, offset}
+	case limit >= 0:
+		return "LIMIT ?", []interface{}{limit}
+	case offset >= 0:
+		// sqlite的OFFSET必须跟在LIMIT之后，-1表示不限制
+		return "LIMIT -1 OFFSET ?", []interface{}{offset}
+	}
+	return "", nil
+}
+
+func (sqliteDialect) Supports(feature Feature) bool {
+	switch feature {
+	case FeatureReturning, FeatureOnConflict, FeatureReplace, FeatureIntersect:
+		return true
+	}
+	return false
+}
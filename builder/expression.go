@@ -6,6 +6,8 @@ type Expression struct {
 	specification string
 	values        []interface{}
 	err           error
+	sub           nestedQuery // 子查询，以specification和suffix包裹，在外层语句生成时生成
+	suffix        string
 }
 
 func NewExpression(specification string, values ...interface{}) *Expression {
@@ -22,11 +24,25 @@ func ErrExpression(err error) *Expression {
 }
 
 func (e *Expression) GetSpecification() string {
-	return e.specification
+	spec, _, _ := e.resolve(nil)
+	return spec
 }
 
 func (e *Expression) GetValues() []interface{} {
-	return e.values
+	_, values, _ := e.resolve(nil)
+	return values
+}
+
+// resolve 生成表达式及参数，子查询以外层语句的方言生成，dialect为nil时使用子查询自身的方言
+func (e *Expression) resolve(dialect Dialect) (string, []interface{}, error) {
+	if e.sub == nil {
+		return e.specification, e.values, e.err
+	}
+	sql, params, err := e.sub.buildIn(dialect)
+	if err != nil {
+		return "", nil, err
+	}
+	return e.specification + sql + e.suffix, append(append([]interface{}{}, e.values...), params...), nil
 }
 
 func (e *Expression) GetExpressionData() ([]interface{}, error) {
@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"strings"
+)
+
+// IndexScope 索引提示的作用范围
+type IndexScope string
+
+const (
+	ForJoin    IndexScope = "FOR JOIN"
+	ForOrderBy IndexScope = "FOR ORDER BY"
+	ForGroupBy IndexScope = "FOR GROUP BY"
+)
+
+// IndexHint MySQL的USE、IGNORE、FORCE INDEX索引提示
+type IndexHint struct {
+	typo    string
+	scope   IndexScope
+	indexes []string
+}
+
+func newIndexHint(typo string, indexes []string) *IndexHint {
+	hint := &IndexHint{typo: typo, indexes: make([]string, 0, len(indexes))}
+	for _, index := range indexes {
+		if index != "" {
+			hint.indexes = append(hint.indexes, index)
+		}
+	}
+	return hint
+}
+
+func UseIndex(indexes ...string) *IndexHint {
+	return newIndexHint("USE", indexes)
+}
+
+func IgnoreIndex(indexes ...string) *IndexHint {
+	return newIndexHint("IGNORE", indexes)
+}
+
+func ForceIndex(indexes ...string) *IndexHint {
+	return newIndexHint("FORCE", indexes)
+}
+
+// For 设置索引提示的作用范围，如 ForJoin、ForOrderBy、ForGroupBy
+func (h *IndexHint) For(scope IndexScope) *IndexHint {
+	h.scope = scope
+	return h
+}
+
+// empty USE INDEX ()表示不使用任何索引，其余提示没有索引时忽略
+func (h *IndexHint) empty() bool {
+	return len(h.indexes) == 0 && h.typo != "USE"
+}
+
+func (h *IndexHint) String() string {
+	var str = getStrBuilder()
+	defer putStrBuilder(str)
+	str.WriteString(h.typo)
+	str.WriteString(" INDEX ")
+	if h.scope != "" {
+		str.WriteString(string(h.scope))
+		str.WriteString(" ")
+	}
+	str.WriteString("(")
+	for i, index := range h.indexes {
+		if i > 0 {
+			str.WriteString(", ")
+		}
+		str.WriteString(QuoteIdentifier(index))
+	}
+	str.WriteString(")")
+	return str.String()
+}
+
+func processIndexHints(dialect Dialect, hints []*IndexHint) (string, error) {
+	var str = getStrBuilder()
+	defer putStrBuilder(str)
+	for _, hint := range hints {
+		if hint == nil || hint.empty() {
+			continue
+		}
+		if !dialect.Supports(FeatureIndexHint) {
+			return "", ErrNotSupportDialect
+		}
+		if str.Len() > 0 {
+			str.WriteString(" ")
+		}
+		str.WriteString(hint.String())
+	}
+	return str.String(), nil
+}
+
+// joinIndexHints 返回连接表的索引提示，table可为表名或别名
+func joinIndexHints(hints map[string][]*IndexHint, attr joinAttr) []*IndexHint {
+	if len(hints) == 0 || attr.subQuery != nil {
+		return nil
+	}
+	name, alias := resolveIdentifier(attr.name)
+	for _, key := range []string{strings.TrimSpace(attr.name), alias, name} {
+		if h, ok := hints[key]; ok {
+			return h
+		}
+	}
+	return nil
+}
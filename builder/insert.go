@@ -1,9 +1,23 @@
 package builder
 
+type insertMode int
+
+const (
+	insertModeInsert insertMode = iota
+	insertModeIgnore
+	insertModeReplace
+)
+
 type Inserter struct {
-	table   string
-	columns []string
-	params  [][]interface{}
+	table           string
+	columns         []string
+	returning       []string
+	params          [][]interface{}
+	mode            insertMode
+	conflict        []string      // ON CONFLICT的冲突列
+	duplicate       []string      // 主键或唯一键冲突时更新的列或表达式
+	duplicateParams []interface{} // 冲突更新表达式的参数
+	dialect         Dialect
 }
 
 func Insert() *Inserter {
@@ -17,12 +31,43 @@ func (i *Inserter) addParams(params ...[]interface{}) {
 	i.params = append(i.params, params...)
 }
 
+func (i *Inserter) getDialect() Dialect {
+	return resolveDialect(i.dialect)
+}
+
+func (i *Inserter) Dialect(dialect Dialect) *Inserter {
+	i.dialect = dialect
+	return i
+}
+
 func (i *Inserter) Table(table string) *Inserter {
 	i.table = table
 	return i
 }
 
+// Values 设置插入的记录，列按首次出现的记录中列名排序输出，缺少的列插入NULL
 func (i *Inserter) Values(values ...map[string]interface{}) *Inserter {
+	i.resetValues()
+	for _, value := range values {
+		value := value
+		i.addRow(sortedKeys(value), func(k string) (interface{}, bool) {
+			v, ok := value[k]
+			return v, ok
+		})
+	}
+	return i
+}
+
+// ValuesOrdered 设置插入的记录，列按添加顺序输出
+func (i *Inserter) ValuesOrdered(values ...*OrderedMap) *Inserter {
+	i.resetValues()
+	for _, value := range values {
+		i.addRow(value.Keys(), value.Get)
+	}
+	return i
+}
+
+func (i *Inserter) resetValues() {
 	if i.columns == nil {
 		i.columns = make([]string, 0)
 		i.params = make([][]interface{}, 0)
@@ -30,28 +75,62 @@ func (i *Inserter) Values(values ...map[string]interface{}) *Inserter {
 		i.columns = i.columns[0:0]
 		i.params = i.params[0:0]
 	}
-	for _, value := range values {
-		var params = make([]interface{}, 0)
-		if len(i.columns) == 0 {
-			for k, v := range value {
-				i.columns = append(i.columns, k)
-				params = append(params, v)
-			}
-		} else {
-			for _, v := range i.columns {
-				if val, ok := value[v]; ok {
-					params = append(params, val)
-					delete(value, v)
-				} else {
-					params = append(params, nil)
-				}
-			}
-			for k, v := range value {
-				i.columns = append(i.columns, k)
-				params = append(params, v)
-			}
+}
+
+// addRow 已有的列按原顺序取值，新出现的列追加在后
+func (i *Inserter) addRow(keys []string, get func(string) (interface{}, bool)) {
+	var (
+		params = make([]interface{}, 0, len(keys))
+		known  = make(map[string]struct{}, len(i.columns))
+	)
+	for _, column := range i.columns {
+		known[column] = struct{}{}
+		val, _ := get(column)
+		params = append(params, val)
+	}
+	for _, k := range keys {
+		if _, ok := known[k]; ok {
+			continue
 		}
-		i.addParams(params)
+		val, _ := get(k)
+		i.columns = append(i.columns, k)
+		params = append(params, val)
 	}
+	i.addParams(params)
+}
+
+func (i *Inserter) Returning(columns ...string) *Inserter {
+	i.returning = columns
+	return i
+}
+
+// Ignore 忽略主键或唯一键冲突的记录
+func (i *Inserter) Ignore() *Inserter {
+	i.mode = insertModeIgnore
+	return i
+}
+
+// Replace 主键或唯一键冲突时删除原记录后插入
+func (i *Inserter) Replace() *Inserter {
+	i.mode = insertModeReplace
+	return i
+}
+
+// OnConflict 设置冲突列，不支持ON DUPLICATE KEY的方言以ON CONFLICT实现冲突更新
+func (i *Inserter) OnConflict(columns ...string) *Inserter {
+	i.conflict = columns
+	return i
+}
+
+// OnDuplicateKeyUpdate 主键或唯一键冲突时更新，列名更新为插入的值，包含=的作为表达式原样写入
+func (i *Inserter) OnDuplicateKeyUpdate(columns ...string) *Inserter {
+	i.duplicate = append(i.duplicate, columns...)
+	return i
+}
+
+// OnDuplicateKeyUpdateExpr 主键或唯一键冲突时以带参数的表达式更新，如 "`count`=`count`+?"
+func (i *Inserter) OnDuplicateKeyUpdateExpr(expr string, params ...interface{}) *Inserter {
+	i.duplicate = append(i.duplicate, expr)
+	i.duplicateParams = append(i.duplicateParams, params...)
 	return i
 }
@@ -14,14 +14,16 @@ const (
 )
 
 type joinAttr struct {
-	name    string
-	on      string
-	columns []string
-	typo    string
+	name     string // 表名，子查询时为别名
+	subQuery *SubQuery
+	on       *Predicate
+	columns  []string
+	typo     string
 }
 
 type join struct {
 	joins []joinAttr
+	hints map[string][]*IndexHint // 连接表的索引提示，键为表名或别名
 }
 
 func newJoin() *join {
@@ -32,16 +34,40 @@ func (j *join) GetJoins() []joinAttr {
 	return j.joins
 }
 
-func (j *join) join(name string, on []string, joinType string, columns ...string) *join {
-	for i, onv := range on {
-		on[i] = QuoteIdentifier(onv)
-	}
-	j.joins = append(j.joins, joinAttr{
-		name:    name,
-		on:      strings.Join(on, "="),
+// join name为表名或*SubQuery，on为相等的列名[]string，或与Where相同的map、*clause、PredicateIfe
+func (j *join) join(name interface{}, on interface{}, joinType string, columns ...string) *join {
+	attr := joinAttr{
 		columns: columns,
 		typo:    joinType,
-	})
+	}
+	if o, ok := on.([]string); ok {
+		var quoted = make([]string, len(o))
+		for i, onv := range o {
+			quoted[i] = QuoteIdentifier(onv)
+		}
+		attr.on = NewPredicate().AddPredicate(NewExpression(strings.Join(quoted, "=")), defaultCombination)
+	} else if c, ok := on.(*clause); ok && c != nil {
+		attr.on = c.predicate
+	} else {
+		attr.on = buildPredicate(on)
+	}
+	switch n := name.(type) {
+	case string:
+		attr.name = n
+	case *SubQuery:
+		attr.name = n.alias
+		attr.subQuery = n
+	}
+	j.joins = append(j.joins, attr)
+	return j
+}
+
+func (j *join) hint(table string, hints ...*IndexHint) *join {
+	if j.hints == nil {
+		j.hints = make(map[string][]*IndexHint)
+	}
+	table = strings.TrimSpace(table)
+	j.hints[table] = append(j.hints[table], hints...)
 	return j
 }
 
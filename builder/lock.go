@@ -0,0 +1,41 @@
+package builder
+
+// LockMode 锁定读的模式
+type LockMode int
+
+const (
+	LockNone LockMode = iota
+	LockForUpdate
+	LockForUpdateNoWait
+	LockForUpdateSkipLocked
+	LockForShare
+	LockForShareNoWait
+	LockForShareSkipLocked
+)
+
+func (m LockMode) String() string {
+	switch m {
+	case LockForUpdate:
+		return "FOR UPDATE"
+	case LockForUpdateNoWait:
+		return "FOR UPDATE NOWAIT"
+	case LockForUpdateSkipLocked:
+		return "FOR UPDATE SKIP LOCKED"
+	case LockForShare:
+		return "FOR SHARE"
+	case LockForShareNoWait:
+		return "FOR SHARE NOWAIT"
+	case LockForShareSkipLocked:
+		return "FOR SHARE SKIP LOCKED"
+	}
+	return ""
+}
+
+// wait 是否为NOWAIT或SKIP LOCKED
+func (m LockMode) wait() bool {
+	switch m {
+	case LockForUpdateNoWait, LockForUpdateSkipLocked, LockForShareNoWait, LockForShareSkipLocked:
+		return true
+	}
+	return false
+}
@@ -81,6 +81,16 @@ func QuoteIdentifier(identifier string) string {
 }
 
 func operate(left, operator string, right interface{}) *Expression {
+	if sub, ok := right.(*Selector); ok {
+		return subQueryExpression(QuoteIdentifier(left)+operator+"(", sub, ")")
+	}
+	// 与另一列或表达式比较，如 Clause("t1.id", Col("t2.tid"))
+	if col, ok := right.(*Column); ok {
+		if col.err != nil {
+			return ErrExpression(col.err)
+		}
+		return NewExpression(QuoteIdentifier(left)+operator+col.expr, col.params...)
+	}
 	var str = getStrBuilder()
 	defer putStrBuilder(str)
 	str.WriteString(QuoteIdentifier(left))
@@ -105,33 +115,52 @@ func notBetween(identifier string, minValue, maxValue interface{}) *Expression {
 	return NewExpression(str.String(), minValue, maxValue)
 }
 
-func exists(specification string, values ...interface{}) *Expression {
-	placeHolderCount := strings.Count(specification, PlaceHolder)
+func exists(specification interface{}, values ...interface{}) *Expression {
+	if sub, ok := specification.(*Selector); ok {
+		return subQueryExpression("EXISTS(", sub, ")")
+	}
+	spec, ok := specification.(string)
+	if !ok {
+		return ErrExpression(ErrNotSupportProcess)
+	}
+	placeHolderCount := strings.Count(spec, PlaceHolder)
 	if placeHolderCount > len(values) {
 		return ErrExpression(ErrBuildPlaceHolder)
 	}
 	var str = getStrBuilder()
 	defer putStrBuilder(str)
 	str.WriteString("EXISTS(")
-	str.WriteString(specification)
+	str.WriteString(spec)
 	str.WriteString(")")
 	return NewExpression(str.String(), values...)
 }
 
-func notExists(specification string, values ...interface{}) *Expression {
-	placeHolderCount := strings.Count(specification, PlaceHolder)
+func notExists(specification interface{}, values ...interface{}) *Expression {
+	if sub, ok := specification.(*Selector); ok {
+		return subQueryExpression("NOT EXISTS(", sub, ")")
+	}
+	spec, ok := specification.(string)
+	if !ok {
+		return ErrExpression(ErrNotSupportProcess)
+	}
+	placeHolderCount := strings.Count(spec, PlaceHolder)
 	if placeHolderCount > len(values) {
 		return ErrExpression(ErrBuildPlaceHolder)
 	}
 	var str = getStrBuilder()
 	defer putStrBuilder(str)
 	str.WriteString("NOT EXISTS(")
-	str.WriteString(specification)
+	str.WriteString(spec)
 	str.WriteString(")")
 	return NewExpression(str.String(), values...)
 }
 
 func in(identifier string, values ...interface{}) *Expression {
+	if len(values) == 1 {
+		if sub, ok := values[0].(*Selector); ok {
+			return subQueryExpression(QuoteIdentifier(identifier)+" IN (", sub, ")")
+		}
+	}
 	var str = getStrBuilder()
 	defer putStrBuilder(str)
 	str.WriteString(QuoteIdentifier(identifier))
@@ -147,6 +176,11 @@ func in(identifier string, values ...interface{}) *Expression {
 }
 
 func notIn(identifier string, values ...interface{}) *Expression {
+	if len(values) == 1 {
+		if sub, ok := values[0].(*Selector); ok {
+			return subQueryExpression(QuoteIdentifier(identifier)+" NOT IN (", sub, ")")
+		}
+	}
 	var str = getStrBuilder()
 	defer putStrBuilder(str)
 	str.WriteString(QuoteIdentifier(identifier))
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"bytes"
+	"sort"
 	"strings"
 )
 
@@ -37,11 +38,16 @@ func (s *Selector) processSelect() (string, error) {
 		}
 	}
 
-	// join column
+	// join column，主表未指定列时查询主表的全部列
 	if selectJoin != nil {
+		mainTableName := aliasTableName
 		for _, joinAttr := range selectJoin.GetJoins() {
 			_, aliasTableName := resolveIdentifier(joinAttr.name)
 			for _, c := range joinAttr.columns {
+				if len(selectColumns) == 0 && columnStr.Len() == 0 {
+					columnStr.WriteString(QuoteTable(mainTableName))
+					columnStr.WriteString(".*")
+				}
 				if columnStr.Len() > 0 {
 					columnStr.WriteString(", ")
 				}
@@ -56,16 +62,50 @@ func (s *Selector) processSelect() (string, error) {
 		}
 	}
 
+	// expression column
+	for _, c := range s.eColumns {
+		if c.err != nil {
+			return "", c.err
+		}
+		if columnStr.Len() > 0 {
+			columnStr.WriteString(", ")
+		}
+		columnStr.WriteString(c.expr)
+		if c.alias != "" {
+			columnStr.WriteString(" AS ")
+			columnStr.WriteString(QuoteIdentifier(c.alias))
+		}
+		s.addParams(c.params...)
+	}
+
 	// func column
-	for alias, fColumns := range s.fColumns {
+	fAliases := make([]string, 0, len(s.fColumns))
+	for alias := range s.fColumns {
+		fAliases = append(fAliases, alias)
+	}
+	sort.Strings(fAliases)
+	for _, alias := range fAliases {
 		if columnStr.Len() > 0 {
 			columnStr.WriteString(", ")
 		}
-		columnStr.WriteString(fColumns)
+		columnStr.WriteString(s.fColumns[alias])
 		columnStr.WriteString(" AS ")
 		columnStr.WriteString(QuoteIdentifier(alias))
 	}
 
+	// sub query column
+	for _, sub := range s.subColumns {
+		sql, params, err := sub.build(s.getDialect())
+		if err != nil {
+			return "", err
+		}
+		if columnStr.Len() > 0 {
+			columnStr.WriteString(", ")
+		}
+		columnStr.WriteString(sql)
+		s.addParams(params...)
+	}
+
 	if columnStr.Len() == 0 {
 		columnStr.WriteString(QuoteTable(aliasTableName))
 		columnStr.WriteString(".*")
@@ -73,43 +113,29 @@ func (s *Selector) processSelect() (string, error) {
 
 	str.Write(columnStr.Bytes())
 
-	if selectTable != "" {
+	if s.from != nil {
+		sql, params, err := s.from.build(s.getDialect())
+		if err != nil {
+			return "", err
+		}
+		str.WriteString(" FROM ")
+		str.WriteString(sql)
+		s.addParams(params...)
+	} else if selectTable != "" {
 		str.WriteString(" FROM ")
 		str.WriteString(QuoteTable(selectTable))
 	}
 	return str.String(), nil
 }
 
-func (s *Selector) processForceIndex() (string, error) {
-	if s.forceIndex == "" {
-		return "", nil
-	}
-	var str = getStrBuilder()
-	defer putStrBuilder(str)
-	str.WriteString("FORCE INDEX (")
-	str.WriteString(QuoteIdentifier(s.forceIndex))
-	str.WriteString(")")
-	return str.String(), nil
+func (s *Selector) processIndexHints() (string, error) {
+	return processIndexHints(s.getDialect(), s.indexHints)
 }
 
 func (s *Selector) processJoins() (string, error) {
-	join := s.join
-	if join == nil || join.count() == 0 {
-		return "", nil
-	}
-	var str = getStrBuilder()
-	defer putStrBuilder(str)
-	for _, joinAttr := range join.GetJoins() {
-		if str.Len() > 0 {
-			str.WriteString(" ")
-		}
-		str.WriteString(joinAttr.typo)
-		str.WriteString(" JOIN ")
-		str.WriteString(QuoteTable(joinAttr.name))
-		str.WriteString(" ON ")
-		str.WriteString(joinAttr.on)
-	}
-	return str.String(), nil
+	joins, params, err := processJoins(s.getDialect(), s.join)
+	s.addParams(params...)
+	return joins, err
 }
 
 func (s *Selector) processWhere() (string, error) {
@@ -129,8 +155,12 @@ func (s *Selector) processWhere() (string, error) {
 		case string:
 			where.WriteString(p)
 		case *Expression:
-			where.WriteString(p.GetSpecification())
-			s.addParams(p.GetValues()...)
+			spec, values, err := p.resolve(s.getDialect())
+			if err != nil {
+				return "", err
+			}
+			where.WriteString(spec)
+			s.addParams(values...)
 		default:
 			return "", ErrNotSupportProcess
 		}
@@ -172,8 +202,12 @@ func (s *Selector) processHaving() (string, error) {
 		case string:
 			where.WriteString(p)
 		case *Expression:
-			where.WriteString(p.GetSpecification())
-			s.addParams(p.GetValues()...)
+			spec, values, err := p.resolve(s.getDialect())
+			if err != nil {
+				return "", err
+			}
+			where.WriteString(spec)
+			s.addParams(values...)
 		default:
 			return "", ErrNotSupportProcess
 		}
@@ -182,51 +216,27 @@ func (s *Selector) processHaving() (string, error) {
 }
 
 func (s *Selector) processOrder() (string, error) {
-	selectOrder := s.order
-	if selectOrder == nil || len(selectOrder) == 0 {
-		return "", nil
-	}
-	var str = getStrBuilder()
-	defer putStrBuilder(str)
-	str.WriteString("ORDER BY ")
-	for i, order := range selectOrder {
-		if i > 0 {
-			str.WriteString(", ")
-		}
-		o := strings.Split(strings.Trim(order, " "), " ")
-		if len(o) > 0 && len(o) < 3 {
-			str.WriteString(QuoteIdentifier(o[0]))
-			str.WriteString(" ")
-			var sort = "ASC"
-			if len(o) == 2 && o[1] != "" {
-				sort = strings.ToUpper(strings.Trim(o[1], " "))
-			}
-			if sort == "ASC" || sort == "DESC" {
-				str.WriteString(sort)
-				continue
-			}
-		}
-		return "", ErrProcessOrder
-	}
-	return str.String(), nil
+	return processOrder(s.order)
 }
 
 func (s *Selector) processLimit() (string, error) {
-	limit := s.limit
-	if limit < 0 {
+	if s.limit < 0 && s.offset < 0 {
 		return "", nil
 	}
-	s.addParams(limit)
-	return "LIMIT ?", nil
+	limit, params := s.getDialect().LimitOffset(s.limit, s.offset)
+	s.addParams(params...)
+	return limit, nil
 }
 
-func (s *Selector) processOffset() (string, error) {
-	offset := s.offset
-	if offset < 0 {
+func (s *Selector) processLock() (string, error) {
+	if s.lock == LockNone {
 		return "", nil
 	}
-	s.addParams(offset)
-	return "OFFSET ?", nil
+	dialect := s.getDialect()
+	if !dialect.Supports(FeatureLock) || s.lock.wait() && !dialect.Supports(FeatureLockWait) {
+		return "", ErrNotSupportDialect
+	}
+	return s.lock.String(), nil
 }
 
 func (s *Selector) processTail() (string, error) {
@@ -246,19 +256,12 @@ func (u *Updater) processJoins() (string, error) {
 	if join == nil || join.count() == 0 {
 		return "", nil
 	}
-	var str = getStrBuilder()
-	defer putStrBuilder(str)
-	for _, joinAttr := range join.GetJoins() {
-		if str.Len() > 0 {
-			str.WriteString(" ")
-		}
-		str.WriteString(joinAttr.typo)
-		str.WriteString(" JOIN ")
-		str.WriteString(QuoteTable(joinAttr.name))
-		str.WriteString(" ON ")
-		str.WriteString(joinAttr.on)
+	if !u.getDialect().Supports(FeatureUpdateJoin) {
+		return "", ErrNotSupportDialect
 	}
-	return str.String(), nil
+	joins, params, err := processJoins(u.getDialect(), join)
+	u.addParams(params...)
+	return joins, err
 }
 
 func (u *Updater) processSet() (string, error) {
@@ -274,9 +277,29 @@ func (u *Updater) processSet() (string, error) {
 		}
 		if strings.Contains(v, PlaceHolder) {
 			str.WriteString(v)
+			u.addParams(u.setParams[i])
+		} else if sub, ok := u.setParams[i].(*Selector); ok {
+			sql, params, err := sub.buildIn(u.getDialect())
+			if err != nil {
+				return "", err
+			}
+			str.WriteString(QuoteIdentifier(v))
+			str.WriteString("=(")
+			str.WriteString(sql)
+			str.WriteString(")")
+			u.addParams(params...)
+		} else if col, ok := u.setParams[i].(*Column); ok {
+			if col.err != nil {
+				return "", col.err
+			}
+			str.WriteString(QuoteIdentifier(v))
+			str.WriteString("=")
+			str.WriteString(col.expr)
+			u.addParams(col.params...)
 		} else {
 			str.WriteString(QuoteIdentifier(v))
 			str.WriteString("=?")
+			u.addParams(u.setParams[i])
 		}
 	}
 	return str.String(), nil
@@ -298,8 +321,12 @@ func (u *Updater) processWhere() (string, error) {
 		case string:
 			where.WriteString(p)
 		case *Expression:
-			where.WriteString(p.GetSpecification())
-			u.addParams(p.GetValues()...)
+			spec, values, err := p.resolve(u.getDialect())
+			if err != nil {
+				return "", err
+			}
+			where.WriteString(spec)
+			u.addParams(values...)
 		default:
 			return "", ErrNotSupportProcess
 		}
@@ -307,11 +334,91 @@ func (u *Updater) processWhere() (string, error) {
 	return where.String(), nil
 }
 
+func (u *Updater) processLimit() (string, error) {
+	limit, params, err := processModifyLimit(u.getDialect(), u.join, u.order, u.limit)
+	u.addParams(params...)
+	return limit, err
+}
+
+func (u *Updater) processReturning() (string, error) {
+	return processReturning(u.getDialect(), u.returning)
+}
+
+func (c *Compound) processSelectors() (string, error) {
+	if len(c.selectors) < 2 {
+		return "", ErrProcessCompound
+	}
+	var (
+		dialect = c.getDialect()
+		parens  = dialect.Supports(FeatureCompoundParens)
+		str     = getStrBuilder()
+	)
+	defer putStrBuilder(str)
+	for i, s := range c.selectors {
+		if i > 0 {
+			operator := c.operators[i]
+			if (operator == CompoundIntersect || operator == CompoundExcept) && !dialect.Supports(FeatureIntersect) {
+				return "", ErrNotSupportDialect
+			}
+			str.WriteString(" ")
+			str.WriteString(operator)
+			str.WriteString(" ")
+		}
+		sql, params, err := s.buildIn(dialect)
+		if err != nil {
+			return "", err
+		}
+		// 带有ORDER BY或LIMIT的查询需用括号包裹，其余不加括号以兼容递归公用表表达式
+		if len(s.order) > 0 || s.limit >= 0 || s.offset >= 0 {
+			if !parens {
+				return "", ErrNotSupportDialect
+			}
+			str.WriteString("(")
+			str.WriteString(sql)
+			str.WriteString(")")
+		} else {
+			str.WriteString(sql)
+		}
+		c.addParams(params...)
+	}
+	return str.String(), nil
+}
+
+func (c *Compound) processOrder() (string, error) {
+	return processOrder(c.order)
+}
+
+func (c *Compound) processLimit() (string, error) {
+	if c.limit < 0 && c.offset < 0 {
+		return "", nil
+	}
+	limit, params := c.getDialect().LimitOffset(c.limit, c.offset)
+	c.addParams(params...)
+	return limit, nil
+}
+
 func (i *Inserter) processInsert() (string, error) {
 	columns := i.columns
 	var str = getStrBuilder()
 	defer putStrBuilder(str)
-	str.WriteString("INSERT INTO ")
+	switch i.mode {
+	case insertModeIgnore:
+		if i.getDialect().Supports(FeatureInsertIgnore) {
+			str.WriteString("INSERT IGNORE INTO ")
+		} else if i.getDialect().Supports(FeatureOnConflict) {
+			// 由processDuplicate写入ON CONFLICT DO NOTHING
+			str.WriteString("INSERT INTO ")
+		} else {
+			return "", ErrNotSupportDialect
+		}
+	case insertModeReplace:
+		if !i.getDialect().Supports(FeatureReplace) {
+			return "", ErrNotSupportDialect
+		}
+		str.WriteString("REPLACE INTO ")
+	default:
+		str.WriteString("INSERT INTO ")
+	}
 	str.WriteString(QuoteTable(i.table))
 	str.WriteString("(")
 	for c, v := range columns {
@@ -332,14 +439,100 @@ func (i *Inserter) processInsert() (string, error) {
 	return str.String(), nil
 }
 
+func (i *Inserter) processDuplicate() (string, error) {
+	dialect := i.getDialect()
+	if i.mode != insertModeInsert {
+		if len(i.duplicate) > 0 {
+			return "", ErrProcessInsert
+		}
+		if i.mode == insertModeIgnore && !dialect.Supports(FeatureInsertIgnore) {
+			return "ON CONFLICT DO NOTHING", nil
+		}
+		return "", nil
+	}
+	if len(i.duplicate) == 0 {
+		return "", nil
+	}
+	var str = getStrBuilder()
+	defer putStrBuilder(str)
+	// 插入的值，mysql为VALUES(`c`)，ON CONFLICT为EXCLUDED.`c`
+	var inserted func(column string) string
+	if dialect.Supports(FeatureOnDuplicateKey) {
+		str.WriteString("ON DUPLICATE KEY UPDATE ")
+		inserted = func(column string) string {
+			return "VALUES(" + QuoteIdentifier(column) + ")"
+		}
+	} else if dialect.Supports(FeatureOnConflict) && len(i.conflict) > 0 {
+		str.WriteString("ON CONFLICT (")
+		for c, v := range i.conflict {
+			if c > 0 {
+				str.WriteString(", ")
+			}
+			str.WriteString(QuoteIdentifier(v))
+		}
+		str.WriteString(") DO UPDATE SET ")
+		inserted = func(column string) string {
+			return "EXCLUDED." + QuoteIdentifier(column)
+		}
+	} else {
+		return "", ErrNotSupportDialect
+	}
+	for c, v := range i.duplicate {
+		if c > 0 {
+			str.WriteString(", ")
+		}
+		if strings.Contains(v, "=") {
+			str.WriteString(v)
+		} else {
+			str.WriteString(QuoteIdentifier(v))
+			str.WriteString("=")
+			str.WriteString(inserted(v))
+		}
+	}
+	return str.String(), nil
+}
+
+func (i *Inserter) processReturning() (string, error) {
+	return processReturning(i.getDialect(), i.returning)
+}
+
 func (d *Deleter) processDelete() (string, error) {
 	str := getStrBuilder()
 	defer putStrBuilder(str)
-	str.WriteString("DELETE FROM ")
+	str.WriteString("DELETE ")
+	if d.join != nil && d.join.count() > 0 {
+		// 多表删除，默认只删除主表的记录
+		targets := d.targets
+		if len(targets) == 0 {
+			_, alias := resolveIdentifier(d.table)
+			targets = []string{alias}
+		}
+		for i, target := range targets {
+			if i > 0 {
+				str.WriteString(", ")
+			}
+			str.WriteString(QuoteIdentifier(target))
+		}
+		str.WriteString(" ")
+	}
+	str.WriteString("FROM ")
 	str.WriteString(QuoteTable(d.table))
 	return str.String(), nil
 }
 
+func (d *Deleter) processJoins() (string, error) {
+	join := d.join
+	if join == nil || join.count() == 0 {
+		return "", nil
+	}
+	if !d.getDialect().Supports(FeatureDeleteJoin) {
+		return "", ErrNotSupportDialect
+	}
+	joins, params, err := processJoins(d.getDialect(), join)
+	d.addParams(params...)
+	return joins, err
+}
+
 func (d *Deleter) processWhere() (string, error) {
 	if d.where == nil || d.where.count() == 0 {
 		return "", nil
@@ -356,11 +549,236 @@ func (d *Deleter) processWhere() (string, error) {
 		case string:
 			where.WriteString(p)
 		case *Expression:
-			where.WriteString(p.GetSpecification())
-			d.addParams(p.GetValues()...)
+			spec, values, err := p.resolve(d.getDialect())
+			if err != nil {
+				return "", err
+			}
+			where.WriteString(spec)
+			d.addParams(values...)
 		default:
 			return "", ErrNotSupportProcess
 		}
 	}
 	return where.String(), nil
 }
+
+func (d *Deleter) processLimit() (string, error) {
+	limit, params, err := processModifyLimit(d.getDialect(), d.join, d.order, d.limit)
+	d.addParams(params...)
+	return limit, err
+}
+
+func (d *Deleter) processReturning() (string, error) {
+	return processReturning(d.getDialect(), d.returning)
+}
+
+func processReturning(dialect Dialect, returning []string) (string, error) {
+	if len(returning) == 0 {
+		return "", nil
+	}
+	if !dialect.Supports(FeatureReturning) {
+		return "", ErrNotSupportDialect
+	}
+	var str = getStrBuilder()
+	defer putStrBuilder(str)
+	str.WriteString("RETURNING ")
+	for i, c := range returning {
+		if i > 0 {
+			str.WriteString(", ")
+		}
+		str.WriteString(QuoteIdentifier(c))
+	}
+	return str.String(), nil
+}
+
+func processOrder(selectOrder []string) (string, error) {
+	if selectOrder == nil || len(selectOrder) == 0 {
+		return "", nil
+	}
+	var str = getStrBuilder()
+	defer putStrBuilder(str)
+	str.WriteString("ORDER BY ")
+	for i, order := range selectOrder {
+		if i > 0 {
+			str.WriteString(", ")
+		}
+		o := strings.Split(strings.Trim(order, " "), " ")
+		if len(o) > 0 && len(o) < 3 {
+			str.WriteString(QuoteIdentifier(o[0]))
+			str.WriteString(" ")
+			var sort = "ASC"
+			if len(o) == 2 && o[1] != "" {
+				sort = strings.ToUpper(strings.Trim(o[1], " "))
+			}
+			if sort == "ASC" || sort == "DESC" {
+				str.WriteString(sort)
+				continue
+			}
+		}
+		return "", ErrProcessOrder
+	}
+	return str.String(), nil
+}
+
+func processJoins(dialect Dialect, join *join) (string, []interface{}, error) {
+	if join == nil || join.count() == 0 && len(join.hints) == 0 {
+		return "", nil, nil
+	}
+	var (
+		str     = getStrBuilder()
+		params  []interface{}
+		matched int
+	)
+	defer putStrBuilder(str)
+	for _, joinAttr := range join.GetJoins() {
+		if str.Len() > 0 {
+			str.WriteString(" ")
+		}
+		str.WriteString(joinAttr.typo)
+		str.WriteString(" JOIN ")
+		if sub := joinAttr.subQuery; sub != nil {
+			sql, subParams, err := sub.build(dialect)
+			if err != nil {
+				return "", nil, err
+			}
+			str.WriteString(sql)
+			params = append(params, subParams...)
+		} else {
+			str.WriteString(QuoteTable(joinAttr.name))
+		}
+		if hints := joinIndexHints(join.hints, joinAttr); hints != nil {
+			matched++
+			hintStr, err := processIndexHints(dialect, hints)
+			if err != nil {
+				return "", nil, err
+			}
+			if hintStr != "" {
+				str.WriteString(" ")
+				str.WriteString(hintStr)
+			}
+		}
+		if joinAttr.on.count() == 0 {
+			continue
+		}
+		parts, err := joinAttr.on.GetExpressionData()
+		if err != nil {
+			return "", nil, err
+		}
+		str.WriteString(" ON ")
+		for _, part := range parts {
+			switch p := part.(type) {
+			case string:
+				str.WriteString(p)
+			case *Expression:
+				spec, values, err := p.resolve(dialect)
+				if err != nil {
+					return "", nil, err
+				}
+				str.WriteString(spec)
+				params = append(params, values...)
+			default:
+				return "", nil, ErrNotSupportProcess
+			}
+		}
+	}
+	if matched < len(join.hints) {
+		return "", nil, ErrProcessIndexHint
+	}
+	return str.String(), params, nil
+}
+
+// processModifyLimit 处理UPDATE及DELETE的ORDER BY和LIMIT，多表操作时不支持
+func processModifyLimit(dialect Dialect, join *join, order []string, limit int) (string, []interface{}, error) {
+	if len(order) == 0 && limit < 0 {
+		return "", nil, nil
+	}
+	if !dialect.Supports(FeatureModifyLimit) {
+		return "", nil, ErrNotSupportDialect
+	}
+	if join != nil && join.count() > 0 {
+		return "", nil, ErrNotSupportProcess
+	}
+	var str = getStrBuilder()
+	defer putStrBuilder(str)
+	orderStr, err := processOrder(order)
+	if err != nil {
+		return "", nil, err
+	}
+	str.WriteString(orderStr)
+	if limit < 0 {
+		return str.String(), nil, nil
+	}
+	limitStr, params := dialect.LimitOffset(limit, -1)
+	if str.Len() > 0 {
+		str.WriteString(" ")
+	}
+	str.WriteString(limitStr)
+	return str.String(), params, nil
+}
+
+func processWith(dialect Dialect, w *with) (string, []interface{}, error) {
+	if w == nil || w.count() == 0 {
+		return "", nil, nil
+	}
+	var (
+		str    = getStrBuilder()
+		params []interface{}
+	)
+	defer putStrBuilder(str)
+	str.WriteString("WITH ")
+	if w.recursive {
+		str.WriteString("RECURSIVE ")
+	}
+	for i, c := range w.ctes {
+		var (
+			sql       string
+			cteParams []interface{}
+			err       error
+		)
+		if q, ok := c.query.(nestedQuery); ok {
+			sql, cteParams, err = q.buildIn(dialect)
+		} else {
+			err = ErrNotSupportProcess
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if i > 0 {
+			str.WriteString(", ")
+		}
+		str.WriteString(QuoteIdentifier(c.name))
+		if len(c.columns) > 0 {
+			str.WriteString("(")
+			for j, column := range c.columns {
+				if j > 0 {
+					str.WriteString(", ")
+				}
+				str.WriteString(QuoteIdentifier(column))
+			}
+			str.WriteString(")")
+		}
+		str.WriteString(" AS (")
+		str.WriteString(sql)
+		str.WriteString(")")
+		params = append(params, cteParams...)
+	}
+	return str.String(), params, nil
+}
+
+func (s *Selector) processWith() (string, error) {
+	with, params, err := processWith(s.getDialect(), s.with)
+	s.addParams(params...)
+	return with, err
+}
+
+func (u *Updater) processWith() (string, error) {
+	with, params, err := processWith(u.getDialect(), u.with)
+	u.addParams(params...)
+	return with, err
+}
+
+func (d *Deleter) processWith() (string, error) {
+	with, params, err := processWith(d.getDialect(), d.with)
+	d.addParams(params...)
+	return with, err
+}
@@ -5,16 +5,22 @@ type Selector struct {
 	offset     int
 	quantifier string
 	table      string
-	forceIndex string
+	from       *SubQuery // 派生表
+	indexHints []*IndexHint
+	lock       LockMode
 	tail       string
 	fColumns   map[string]string
+	eColumns   []*Column
+	subColumns []*SubQuery
 	columns    []string
 	order      []string
 	group      []string
 	where      *Predicate
 	having     *Predicate
+	with       *with
 	join       *join
 	params     []interface{}
+	dialect    Dialect
 }
 
 func Select() *Selector {
@@ -31,8 +37,25 @@ func (s *Selector) addParams(params ...interface{}) {
 	s.params = append(s.params, params...)
 }
 
-func (s *Selector) Table(table string) *Selector {
-	s.table = table
+func (s *Selector) getDialect() Dialect {
+	return resolveDialect(s.dialect)
+}
+
+func (s *Selector) Dialect(dialect Dialect) *Selector {
+	s.dialect = dialect
+	return s
+}
+
+// Table 设置查询的表，table为表名或作为派生表的*SubQuery
+func (s *Selector) Table(table interface{}) *Selector {
+	switch t := table.(type) {
+	case string:
+		s.table = t
+		s.from = nil
+	case *SubQuery:
+		s.table = t.alias
+		s.from = t
+	}
 	return s
 }
 
@@ -46,12 +69,24 @@ func (s *Selector) FuncColumns(fColumns map[string]string) *Selector {
 	return s
 }
 
+// ExprColumns 以表达式作为查询列，按声明顺序输出，别名通过Column.As设置
+func (s *Selector) ExprColumns(columns ...*Column) *Selector {
+	s.eColumns = append(s.eColumns, columns...)
+	return s
+}
+
+// SubColumns 以子查询作为查询列，列名为子查询的别名
+func (s *Selector) SubColumns(subColumns ...*SubQuery) *Selector {
+	s.subColumns = subColumns
+	return s
+}
+
 func (s *Selector) Columns(columns ...string) *Selector {
 	s.columns = columns
 	return s
 }
 
-func (s *Selector) InnerJoin(name string, on []string, columns ...string) *Selector {
+func (s *Selector) InnerJoin(name interface{}, on interface{}, columns ...string) *Selector {
 	if s.join == nil {
 		s.join = newJoin()
 	}
@@ -59,7 +94,7 @@ func (s *Selector) InnerJoin(name string, on []string, columns ...string) *Selec
 	return s
 }
 
-func (s *Selector) OuterJoin(name string, on []string, columns ...string) *Selector {
+func (s *Selector) OuterJoin(name interface{}, on interface{}, columns ...string) *Selector {
 	if s.join == nil {
 		s.join = newJoin()
 	}
@@ -67,7 +102,7 @@ func (s *Selector) OuterJoin(name string, on []string, columns ...string) *Selec
 	return s
 }
 
-func (s *Selector) LeftJoin(name string, on []string, columns ...string) *Selector {
+func (s *Selector) LeftJoin(name interface{}, on interface{}, columns ...string) *Selector {
 	if s.join == nil {
 		s.join = newJoin()
 	}
@@ -75,7 +110,7 @@ func (s *Selector) LeftJoin(name string, on []string, columns ...string) *Select
 	return s
 }
 
-func (s *Selector) RightJoin(name string, on []string, columns ...string) *Selector {
+func (s *Selector) RightJoin(name interface{}, on interface{}, columns ...string) *Selector {
 	if s.join == nil {
 		s.join = newJoin()
 	}
@@ -83,7 +118,7 @@ func (s *Selector) RightJoin(name string, on []string, columns ...string) *Selec
 	return s
 }
 
-func (s *Selector) LeftOuterJoin(name string, on []string, columns ...string) *Selector {
+func (s *Selector) LeftOuterJoin(name interface{}, on interface{}, columns ...string) *Selector {
 	if s.join == nil {
 		s.join = newJoin()
 	}
@@ -92,7 +127,7 @@ func (s *Selector) LeftOuterJoin(name string, on []string, columns ...string) *S
 
 }
 
-func (s *Selector) RightOuterJoin(name string, on []string, columns ...string) *Selector {
+func (s *Selector) RightOuterJoin(name interface{}, on interface{}, columns ...string) *Selector {
 	if s.join == nil {
 		s.join = newJoin()
 	}
@@ -159,8 +194,36 @@ func (s *Selector) Offset(offset int) *Selector {
 	return s
 }
 
-func (s *Selector) ForceIndex(index string) *Selector {
-	s.forceIndex = index
+func (s *Selector) UseIndex(indexes ...string) *Selector {
+	return s.IndexHint(UseIndex(indexes...))
+}
+
+func (s *Selector) IgnoreIndex(indexes ...string) *Selector {
+	return s.IndexHint(IgnoreIndex(indexes...))
+}
+
+func (s *Selector) ForceIndex(indexes ...string) *Selector {
+	return s.IndexHint(ForceIndex(indexes...))
+}
+
+// IndexHint 为查询的表添加索引提示，可通过For指定作用范围，如 ForceIndex("idx_a").For(ForOrderBy)
+func (s *Selector) IndexHint(hints ...*IndexHint) *Selector {
+	s.indexHints = append(s.indexHints, hints...)
+	return s
+}
+
+// JoinIndexHint 为连接的表添加索引提示，table为连接时使用的表名或别名
+func (s *Selector) JoinIndexHint(table string, hints ...*IndexHint) *Selector {
+	if s.join == nil {
+		s.join = newJoin()
+	}
+	s.join.hint(table, hints...)
+	return s
+}
+
+// Lock 设置锁定读的模式，如 FOR UPDATE SKIP LOCKED
+func (s *Selector) Lock(mode LockMode) *Selector {
+	s.lock = mode
 	return s
 }
 
@@ -168,3 +231,21 @@ func (s *Selector) Tail(tail string) *Selector {
 	s.tail = tail
 	return s
 }
+
+// With 添加公用表表达式，query为*Selector或*Compound，name可带列名，如 "tree(id, parent_id)"
+func (s *Selector) With(name string, query interface{}) *Selector {
+	if s.with == nil {
+		s.with = newWith()
+	}
+	s.with.add(false, name, query)
+	return s
+}
+
+// WithRecursive 添加递归公用表表达式
+func (s *Selector) WithRecursive(name string, query interface{}) *Selector {
+	if s.with == nil {
+		s.with = newWith()
+	}
+	s.with.add(true, name, query)
+	return s
+}
@@ -0,0 +1,41 @@
+package builder
+
+// nestedQuery 可作为子查询嵌入外层语句的查询，即*Selector或*Compound
+type nestedQuery interface {
+	buildIn(dialect Dialect) (string, []interface{}, error)
+}
+
+// SubQuery 带别名的子查询，可作为派生表、连接目标或查询列，在外层语句生成时以其方言生成
+type SubQuery struct {
+	alias string
+	query nestedQuery
+}
+
+// As 将查询作为带别名的子查询
+func (s *Selector) As(alias string) *SubQuery {
+	return &SubQuery{
+		alias: alias,
+		query: s,
+	}
+}
+
+func (q *SubQuery) Alias() string {
+	return q.alias
+}
+
+func (q *SubQuery) build(dialect Dialect) (string, []interface{}, error) {
+	sql, params, err := q.query.buildIn(dialect)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + sql + ") AS " + QuoteIdentifier(q.alias), append([]interface{}{}, params...), nil
+}
+
+// subQueryExpression 以子查询生成表达式，prefix和suffix包裹子查询语句
+func subQueryExpression(prefix string, sub *Selector, suffix string) *Expression {
+	return &Expression{
+		specification: prefix,
+		sub:           sub,
+		suffix:        suffix,
+	}
+}
@@ -1,13 +1,18 @@
 package builder
 
 type Updater struct {
-	table  string
-	set    []string
-	where  *Predicate
-	join   *join
-	limit  int
-	offset int
-	params []interface{}
+	table     string
+	set       []string
+	setParams []interface{}
+	where     *Predicate
+	with      *with
+	join      *join
+	order     []string
+	limit     int
+	offset    int
+	returning []string
+	params    []interface{}
+	dialect   Dialect
 }
 
 func Update() *Updater {
@@ -24,23 +29,71 @@ func (u *Updater) addParams(params ...interface{}) {
 	u.params = append(u.params, params...)
 }
 
+func (u *Updater) getDialect() Dialect {
+	return resolveDialect(u.dialect)
+}
+
+func (u *Updater) Dialect(dialect Dialect) *Updater {
+	u.dialect = dialect
+	return u
+}
+
 func (u *Updater) Table(table string) *Updater {
 	u.table = table
 	return u
 }
 
+// Set 设置更新的列，按列名排序输出，与之前Set、Incr等设置的列合并，同名的列以本次为准
 func (u *Updater) Set(set map[string]interface{}) *Updater {
-	if u.set == nil {
-		u.set = make([]string, 0)
-		u.params = make([]interface{}, 0)
-	} else {
-		u.set = u.set[0:0]
-		u.params = u.params[0:0]
+	for _, k := range sortedKeys(set) {
+		u.addSet(k, set[k])
+	}
+	return u
+}
+
+// SetOrdered 设置更新的列，按添加顺序输出，合并规则同Set
+func (u *Updater) SetOrdered(set *OrderedMap) *Updater {
+	for _, k := range set.Keys() {
+		v, _ := set.Get(k)
+		u.addSet(k, v)
 	}
-	for k, v := range set {
-		u.set = append(u.set, k)
-		u.addParams(v)
+	return u
+}
+
+// Incr 将列的值增加n，如 `views`=`views`+?
+func (u *Updater) Incr(column string, n interface{}) *Updater {
+	return u.addSet(column, Col(column).Add(n))
+}
+
+// Decr 将列的值减少n
+func (u *Updater) Decr(column string, n interface{}) *Updater {
+	return u.addSet(column, Col(column).Sub(n))
+}
+
+// SetExpr 以表达式更新列，占位符数量需与参数一致，如 SetExpr("balance", "`balance`*?", 1.1)
+func (u *Updater) SetExpr(column, expr string, params ...interface{}) *Updater {
+	return u.addSet(column, Expr(expr, params...))
+}
+
+func (u *Updater) SetNull(column string) *Updater {
+	return u.addSet(column, Expr("NULL"))
+}
+
+// SetCase 以CASE WHEN表达式更新列，用于按条件批量更新不同的值
+func (u *Updater) SetCase(column string, c *CaseExpr) *Updater {
+	return u.addSet(column, c.End())
+}
+
+// addSet 已设置过的列替换原值并保持位置，否则追加在后
+func (u *Updater) addSet(column string, value interface{}) *Updater {
+	for i, c := range u.set {
+		if c == column {
+			u.setParams[i] = value
+			return u
+		}
 	}
+	u.set = append(u.set, column)
+	u.setParams = append(u.setParams, value)
 	return u
 }
 
@@ -64,7 +117,29 @@ func (u *Updater) Where(where interface{}) *Updater {
 	return u
 }
 
-func (u *Updater) InnerJoin(name string, on []string, columns ...string) *Updater {
+func (u *Updater) Order(order ...string) *Updater {
+	u.order = order
+	return u
+}
+
+func (u *Updater) Limit(limit int) *Updater {
+	if limit >= 0 {
+		u.limit = limit
+	}
+	return u
+}
+
+// HasWhere 是否设置了非空的where条件
+func (u *Updater) HasWhere() bool {
+	return u.where != nil && u.where.count() > 0
+}
+
+func (u *Updater) Returning(columns ...string) *Updater {
+	u.returning = columns
+	return u
+}
+
+func (u *Updater) InnerJoin(name interface{}, on interface{}, columns ...string) *Updater {
 	if u.join == nil {
 		u.join = newJoin()
 	}
@@ -72,7 +147,7 @@ func (u *Updater) InnerJoin(name string, on []string, columns ...string) *Update
 	return u
 }
 
-func (u *Updater) OuterJoin(name string, on []string, columns ...string) *Updater {
+func (u *Updater) OuterJoin(name interface{}, on interface{}, columns ...string) *Updater {
 	if u.join == nil {
 		u.join = newJoin()
 	}
@@ -80,7 +155,7 @@ func (u *Updater) OuterJoin(name string, on []string, columns ...string) *Update
 	return u
 }
 
-func (u *Updater) LeftJoin(name string, on []string, columns ...string) *Updater {
+func (u *Updater) LeftJoin(name interface{}, on interface{}, columns ...string) *Updater {
 	if u.join == nil {
 		u.join = newJoin()
 	}
@@ -88,7 +163,7 @@ func (u *Updater) LeftJoin(name string, on []string, columns ...string) *Updater
 	return u
 }
 
-func (u *Updater) RightJoin(name string, on []string, columns ...string) *Updater {
+func (u *Updater) RightJoin(name interface{}, on interface{}, columns ...string) *Updater {
 	if u.join == nil {
 		u.join = newJoin()
 	}
@@ -96,7 +171,7 @@ func (u *Updater) RightJoin(name string, on []string, columns ...string) *Update
 	return u
 }
 
-func (u *Updater) LeftOuterJoin(name string, on []string, columns ...string) *Updater {
+func (u *Updater) LeftOuterJoin(name interface{}, on interface{}, columns ...string) *Updater {
 	if u.join == nil {
 		u.join = newJoin()
 	}
@@ -105,10 +180,28 @@ func (u *Updater) LeftOuterJoin(name string, on []string, columns ...string) *Up
 
 }
 
-func (u *Updater) RightOuterJoin(name string, on []string, columns ...string) *Updater {
+func (u *Updater) RightOuterJoin(name interface{}, on interface{}, columns ...string) *Updater {
 	if u.join == nil {
 		u.join = newJoin()
 	}
 	u.join.join(name, on, JoinRightOuter, columns...)
 	return u
 }
+
+// With 添加公用表表达式，query为*Selector或*Compound，name可带列名，如 "tree(id, parent_id)"
+func (u *Updater) With(name string, query interface{}) *Updater {
+	if u.with == nil {
+		u.with = newWith()
+	}
+	u.with.add(false, name, query)
+	return u
+}
+
+// WithRecursive 添加递归公用表表达式
+func (u *Updater) WithRecursive(name string, query interface{}) *Updater {
+	if u.with == nil {
+		u.with = newWith()
+	}
+	u.with.add(true, name, query)
+	return u
+}
@@ -0,0 +1,42 @@
+package builder
+
+import (
+	"strings"
+)
+
+type cte struct {
+	name    string
+	columns []string
+	query   interface{} // *Selector或*Compound
+}
+
+type with struct {
+	recursive bool
+	ctes      []cte
+}
+
+func newWith() *with {
+	return &with{ctes: make([]cte, 0)}
+}
+
+// add name可带列名，如 "tree(id, parent_id)"
+func (w *with) add(recursive bool, name string, query interface{}) *with {
+	var columns []string
+	if i := strings.Index(name, "("); i > 0 && strings.HasSuffix(name, ")") {
+		for _, c := range strings.Split(name[i+1:len(name)-1], ",") {
+			columns = append(columns, strings.Trim(c, " "))
+		}
+		name = name[:i]
+	}
+	w.recursive = w.recursive || recursive
+	w.ctes = append(w.ctes, cte{
+		name:    strings.Trim(name, " "),
+		columns: columns,
+		query:   query,
+	})
+	return w
+}
+
+func (w *with) count() int {
+	return len(w.ctes)
+}
@@ -30,9 +30,12 @@ func (d *Dao) SaveCache(model ModelIfe) {
 	}
 }
 
+// buildKey 缓存key由数据源名、表名及主键值组成，不同数据源的同名表互不影响
 func (d *Dao) buildKey(indexes ...interface{}) (string, error) {
 	var err error
 	buildStr := strings.Builder{}
+	buildStr.WriteString(d.Session().source)
+	buildStr.WriteString("`")
 	buildStr.WriteString(d.tableName)
 	for _, v := range indexes {
 	assert:
@@ -82,8 +85,9 @@ type element struct {
 type modelLruCache struct {
 	elements map[string]*element
 	list     *list.List
-	capacity int // 容量
-	used     int // 使用量
+	capacity int                   // 容量
+	used     int                   // 使用量
+	touched  []map[string]struct{} // 每层事务中修改过的key
 	locker   sync.Mutex
 }
 
@@ -119,9 +123,11 @@ func (lru *modelLruCache) Get(key string) (ModelIfe, error) {
 	if lru.used > 0 {
 		if element, ok := lru.elements[key]; ok {
 			lru.list.MoveToBack(element.listElem)
+			metrics().ObserveCache(cacheTable(key), CacheHit)
 			return element.model, nil
 		}
 	}
+	metrics().ObserveCache(cacheTable(key), CacheMiss)
 	return nil, ModelNotFoundError
 }
 
@@ -134,6 +140,7 @@ func (lru *modelLruCache) Put(key string, model ModelIfe) {
 		return
 	}
 
+	lru.touch(key)
 	if elem, ok := lru.elements[key]; ok {
 		lru.elements[key] = &element{listElem: elem.listElem, model: model}
 		lru.list.MoveToBack(elem.listElem)
@@ -148,6 +155,25 @@ func (lru *modelLruCache) Del(key string) {
 	lru.locker.Lock()
 	defer lru.locker.Unlock()
 
+	lru.touch(key)
+	lru.del(key)
+}
+
+// DelTable 删除数据源中表的所有缓存
+func (lru *modelLruCache) DelTable(source, table string) {
+	lru.locker.Lock()
+	defer lru.locker.Unlock()
+
+	prefix := source + "`" + table + "`"
+	for key := range lru.elements {
+		if strings.HasPrefix(key, prefix) {
+			lru.touch(key)
+			lru.del(key)
+		}
+	}
+}
+
+func (lru *modelLruCache) del(key string) {
 	if element, ok := lru.elements[key]; ok {
 		lru.list.Remove(element.listElem)
 		delete(lru.elements, key)
@@ -155,6 +181,48 @@ func (lru *modelLruCache) Del(key string) {
 	}
 }
 
+func (lru *modelLruCache) touch(key string) {
+	if n := len(lru.touched); n > 0 {
+		lru.touched[n-1][key] = struct{}{}
+	}
+}
+
+// beginTrack 开启一层事务，记录之后修改过的key
+func (lru *modelLruCache) beginTrack() {
+	lru.locker.Lock()
+	defer lru.locker.Unlock()
+	lru.touched = append(lru.touched, make(map[string]struct{}))
+}
+
+// commitTrack 提交一层事务，修改过的key并入外层事务
+func (lru *modelLruCache) commitTrack() {
+	lru.locker.Lock()
+	defer lru.locker.Unlock()
+	n := len(lru.touched)
+	if n == 0 {
+		return
+	}
+	if n > 1 {
+		for key := range lru.touched[n-1] {
+			lru.touched[n-2][key] = struct{}{}
+		}
+	}
+	lru.touched = lru.touched[:n-1]
+}
+
+// rollbackTrack 回滚levels层事务，清除其中修改过的model
+func (lru *modelLruCache) rollbackTrack(levels int) {
+	lru.locker.Lock()
+	defer lru.locker.Unlock()
+	for ; levels > 0 && len(lru.touched) > 0; levels-- {
+		n := len(lru.touched)
+		for key := range lru.touched[n-1] {
+			lru.del(key)
+		}
+		lru.touched = lru.touched[:n-1]
+	}
+}
+
 func (lru *modelLruCache) addElement(key string, model ModelIfe) {
 	lru.used++
 	listElem := lru.list.PushBack(key)
@@ -169,6 +237,18 @@ func (lru *modelLruCache) delListFrontElement() {
 			lru.list.Remove(element.listElem)
 			delete(lru.elements, key)
 			lru.used--
+			metrics().ObserveCache(cacheTable(key), CacheEviction)
 		}
 	}
 }
+
+// cacheTable 返回缓存key对应的表名
+func cacheTable(key string) string {
+	if i := strings.IndexByte(key, '`'); i >= 0 {
+		key = key[i+1:]
+	}
+	if i := strings.IndexByte(key, '`'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
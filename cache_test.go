@@ -0,0 +1,109 @@
+package sorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	BaseModel
+	Id    int    `db:"id,pk"`
+	Name  string `db:"name"`
+	Views int    `db:"views"`
+}
+
+func TestCacheKey_Source(t *testing.T) {
+	ass := assert.New(t)
+	sess := NewSession(context.TODO()).Use("a")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	_, err := dao.CreateObj(map[string]interface{}{"id": 1, "name": "a", "views": 0})
+	ass.Nil(err)
+	_, err = dao.QueryCache(1)
+	ass.Nil(err)
+
+	sess.Use("b")
+	_, err = dao.QueryCache(1)
+	ass.Equal(ModelNotFoundError, err)
+
+	sess.Use("a")
+	sess.daoModelCache.DelTable("b", dao.tableName)
+	_, err = dao.QueryCache(1)
+	ass.Nil(err)
+	sess.daoModelCache.DelTable("a", dao.tableName)
+	_, err = dao.QueryCache(1)
+	ass.Equal(ModelNotFoundError, err)
+}
+
+func TestCacheTrack_NestedCommit(t *testing.T) {
+	ass := assert.New(t)
+	lru := newDaoLru(10)
+	lru.Put("outside", &testUser{})
+
+	lru.beginTrack()
+	lru.Put("k1", &testUser{})
+	lru.beginTrack()
+	lru.Put("k2", &testUser{})
+	lru.commitTrack()
+	ass.Len(lru.touched, 1)
+	ass.Contains(lru.touched[0], "k1")
+	ass.Contains(lru.touched[0], "k2")
+
+	// 内层提交后修改并入外层，外层回滚时一并清除
+	lru.rollbackTrack(1)
+	ass.Len(lru.touched, 0)
+	for _, key := range []string{"k1", "k2"} {
+		_, err := lru.Get(key)
+		ass.Equal(ModelNotFoundError, err)
+	}
+	_, err := lru.Get("outside")
+	ass.Nil(err)
+}
+
+func TestCacheTrack_NestedRollback(t *testing.T) {
+	ass := assert.New(t)
+	lru := newDaoLru(10)
+
+	lru.beginTrack()
+	lru.Put("k1", &testUser{})
+	lru.beginTrack()
+	lru.Put("k2", &testUser{})
+	lru.rollbackTrack(1)
+	ass.Len(lru.touched, 1)
+	_, err := lru.Get("k2")
+	ass.Equal(ModelNotFoundError, err)
+	_, err = lru.Get("k1")
+	ass.Nil(err)
+
+	lru.commitTrack()
+	ass.Len(lru.touched, 0)
+	_, err = lru.Get("k1")
+	ass.Nil(err)
+}
+
+func TestCacheTrack_RollbackAll(t *testing.T) {
+	ass := assert.New(t)
+	lru := newDaoLru(10)
+	lru.Put("outside", &testUser{})
+
+	lru.beginTrack()
+	lru.Put("k1", &testUser{})
+	lru.beginTrack()
+	lru.Del("outside")
+	lru.beginTrack()
+	lru.Put("k3", &testUser{})
+	lru.rollbackTrack(3)
+	ass.Len(lru.touched, 0)
+	for _, key := range []string{"k1", "outside", "k3"} {
+		_, err := lru.Get(key)
+		ass.Equal(ModelNotFoundError, err)
+	}
+
+	// 未开启事务时不记录修改
+	lru.Put("k4", &testUser{})
+	lru.rollbackTrack(1)
+	_, err := lru.Get("k4")
+	ass.Nil(err)
+}
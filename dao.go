@@ -5,23 +5,32 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/xkisas/sorm/builder"
-	"github.com/xkisas/sorm/db"
 	"github.com/xkisas/sorm/internal"
 )
 
-const defaultTagName = "db"
+const (
+	defaultTagName         = "db"
+	defaultInsertBatchSize = 500
+)
 
 type DaoIfe interface {
 	initDao(dao DaoIfe, tableName string, indexFields, fields []string, session *Session, modelType reflect.Type, notFoundError error)
 	buildWhere(indexes ...interface{}) (map[string]interface{}, error)
 	update(model ModelIfe, data map[string]interface{}) (int64, error)
+	increment(model ModelIfe, column string, delta interface{}) (int64, error)
 	remove(model ModelIfe) error
 	Session() *Session
 	GetTableName() string
 	Insert(data map[string]interface{}, indexValues ...interface{}) (model ModelIfe, err error)
+	InsertMulti(data []map[string]interface{}, opts ...Option) ([]ModelIfe, error)
+	UpdateWhere(set map[string]interface{}, where interface{}, opts ...Option) (int64, error)
+	Increment(column string, delta interface{}, where interface{}, opts ...Option) (int64, error)
+	DeleteWhere(where interface{}, opts ...Option) (int64, error)
 	Select(forUpdate bool, indexValues ...interface{}) (ModelIfe, error)
 	SelectById(id interface{}, opts ...Option) (ModelIfe, error)
 	SelectOne(where interface{}, opts ...Option) (ModelIfe, error)
@@ -50,6 +59,7 @@ type Dao struct {
 var (
 	ModelRuntimeError  = errors.New("model runtime error")
 	ModelNotFoundError = errors.New("model not found error")
+	ErrEmptyWhere      = errors.New("where clause is empty")
 )
 
 func (d *Dao) initDao(dao DaoIfe, tableName string, indexFields, fields []string, session *Session, modelType reflect.Type, notFoundError error) {
@@ -103,6 +113,7 @@ func (d *Dao) CreateObj(data map[string]interface{}, indexValues ...interface{})
 		}
 	} else {
 		indexValuesCopy = make([]interface{}, len(indexValues))
+		data = copyData(data)
 		for index, indexName := range d.indexFields {
 			data[indexName] = indexValues[index]
 		}
@@ -134,7 +145,7 @@ func (d *Dao) update(model ModelIfe, data map[string]interface{}) (int64, error)
 	if err != nil {
 		return 0, err
 	}
-	query, params, err := builder.Update().Table(d.GetTableName()).Set(data).Where(where).Build()
+	query, params, err := d.BuildUpdate().Set(data).Where(where).Build()
 	if err != nil {
 		return 0, err
 	}
@@ -144,6 +155,10 @@ func (d *Dao) update(model ModelIfe, data map[string]interface{}) (int64, error)
 	}
 	affected, err := result.RowsAffected()
 	if affected == 1 {
+		if hasExpression(data) {
+			d.RemoveCache(model.IndexValues()...)
+			return affected, err
+		}
 		if err = internal.ScanStruct(data, model, defaultTagName, true); err != nil {
 			return affected, err
 		}
@@ -153,13 +168,39 @@ func (d *Dao) update(model ModelIfe, data map[string]interface{}) (int64, error)
 	return affected, err
 }
 
+// increment 将model的数值字段原子地加上delta，并同步更新缓存的model
+func (d *Dao) increment(model ModelIfe, column string, delta interface{}) (int64, error) {
+	where, err := d.buildWhere(model.IndexValues()...)
+	if err != nil {
+		return 0, err
+	}
+	query, params, err := d.BuildUpdate().Incr(column, delta).Where(where).Build()
+	if err != nil {
+		return 0, err
+	}
+	result, err := d.ExecWithSql(query, params)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if affected == 1 {
+		// 部分加载的model无法得知更新后的值，清除缓存
+		if !model.Loaded() || internal.AddField(model, defaultTagName, column, delta) != nil {
+			d.RemoveCache(model.IndexValues()...)
+			return affected, err
+		}
+		d.SaveCache(model)
+	}
+	return affected, err
+}
+
 func (d *Dao) remove(model ModelIfe) error {
 	indexValues := model.IndexValues()
 	where, err := d.buildWhere(indexValues...)
 	if err != nil {
 		return err
 	}
-	query, params, err := builder.Delete().Table(d.GetTableName()).Where(where).Build()
+	query, params, err := d.BuildDelete().Where(where).Build()
 	if err != nil {
 		return err
 	}
@@ -195,11 +236,36 @@ func (d *Dao) Insert(data map[string]interface{}, indexValues ...interface{}) (m
 			pk = append(pk, index)
 		}
 	}
-	query, params, err := builder.Insert().Table(d.GetTableName()).Values(data).Build()
+	// 不支持LastInsertId的方言通过RETURNING获取自增主键
+	var (
+		inserter  = d.BuildInsert().Values(data)
+		returning = len(pk) == 0 && len(d.indexFields) == 1 && d.Session().Dialect().Supports(builder.FeatureReturning)
+	)
+	if returning {
+		inserter.Returning(d.indexFields[0])
+	}
+	query, params, err := inserter.Build()
 	if err != nil {
 		return nil, err
 	}
 	err = d.Session().runInTransaction(func() error {
+		if returning {
+			rows, err := d.Session().queryWrite(query, params...)
+			if err != nil {
+				return err
+			}
+			result, err := ResolveDataFromRows(rows)
+			if err != nil {
+				return err
+			} else if len(result) != 1 {
+				return NewError(ModelRuntimeError, "dao.baseInsert error")
+			}
+			id := result[0][d.indexFields[0]]
+			data[d.indexFields[0]] = id
+			pk = append(pk, id)
+			model, err = d.CreateObj(data, pk...)
+			return err
+		}
 		result, err := d.ExecWithSql(query, params)
 		if err != nil {
 			return err
@@ -221,8 +287,113 @@ func (d *Dao) Insert(data map[string]interface{}, indexValues ...interface{}) (m
 	return
 }
 
-func (d *Dao) Upsert(data map[string]interface{}, indexValues ...interface{}) (model ModelIfe, err error) {
+// InsertMulti 分批插入多条记录，列不同的相邻记录分为不同的语句插入，缺少的列使用默认值
+// 不支持RETURNING的方言按LastInsertId连续推算自增主键，要求auto_increment_increment为1，
+// 且innodb_autoinc_lock_mode为0或1，为2(MySQL 8.0默认)时并发插入会使同一语句的主键不连续
+func (d *Dao) InsertMulti(data []map[string]interface{}, opts ...Option) ([]ModelIfe, error) {
+	if len(data) == 0 {
+		return []ModelIfe{}, nil
+	}
+	var (
+		option   = fetchOption(opts...)
+		withPk   = 0
+		autoIncr = len(d.indexFields) == 1
+	)
+	for _, row := range data {
+		if _, ok := d.getIndexValuesFromData(row); ok {
+			withPk++
+		}
+	}
+	if withPk == len(data) {
+		autoIncr = false
+	} else if withPk > 0 || !autoIncr {
+		return nil, NewError(ModelRuntimeError, "dao.InsertMulti index values must be all set or all unset")
+	}
+	returning := autoIncr && d.Session().Dialect().Supports(builder.FeatureReturning)
+	models := make([]ModelIfe, 0, len(data))
+	err := d.Session().runInTransaction(func() error {
+		for start, end := 0, 0; start < len(data); start = end {
+			for end = start + 1; end < len(data) && end-start < option.batchSize; end++ {
+				if !sameColumns(data[start], data[end]) {
+					break
+				}
+			}
+			batch := data[start:end]
+			inserter := d.BuildInsert().Values(batch...)
+			if returning {
+				inserter.Returning(d.indexFields[0])
+			}
+			query, params, err := inserter.Build()
+			if err != nil {
+				return err
+			}
+			var ids = make([]interface{}, 0, len(batch))
+			if returning {
+				rows, err := d.Session().queryWrite(query, params...)
+				if err != nil {
+					return err
+				}
+				result, err := ResolveDataFromRows(rows)
+				if err != nil {
+					return err
+				} else if len(result) != len(batch) {
+					return NewError(ModelRuntimeError, "dao.InsertMulti error")
+				}
+				for _, r := range result {
+					ids = append(ids, r[d.indexFields[0]])
+				}
+			} else {
+				result, err := d.ExecWithSql(query, params)
+				if err != nil {
+					return err
+				}
+				if affected, err := result.RowsAffected(); err != nil {
+					return err
+				} else if affected != int64(len(batch)) {
+					return NewError(ModelRuntimeError, "dao.InsertMulti error")
+				}
+				if autoIncr {
+					// 多行插入时LastInsertId为第一条记录的自增主键
+					first, err := result.LastInsertId()
+					if err != nil {
+						return err
+					}
+					for i := range batch {
+						ids = append(ids, first+int64(i))
+					}
+				}
+			}
+			for i, row := range batch {
+				var pk []interface{}
+				if autoIncr {
+					pk = append(pk, ids[i])
+				}
+				model, err := d.CreateObj(row, pk...)
+				if err != nil {
+					return err
+				}
+				models = append(models, model)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Upsert 以单条语句插入记录，主键冲突时更新，并以写入的值刷新缓存的model
+// MySQL在任一唯一键冲突时均会更新，ON CONFLICT的方言只处理主键冲突，需按唯一键更新时使用UpsertOn
+func (d *Dao) Upsert(data map[string]interface{}, indexValues ...interface{}) (ModelIfe, error) {
+	return d.UpsertOn(nil, data, indexValues...)
+}
+
+// UpsertOn 以单条语句插入记录，conflict列冲突时更新，conflict为空时为主键
+// conflict只用于ON CONFLICT的方言，MySQL以ON DUPLICATE KEY处理所有唯一键
+func (d *Dao) UpsertOn(conflict []string, data map[string]interface{}, indexValues ...interface{}) (model ModelIfe, err error) {
 	var pk = make([]interface{}, 0)
+	data = copyData(data)
 	if len(indexValues) > 0 {
 		if len(indexValues) != len(d.indexFields) {
 			return nil, NewError(ModelRuntimeError, "indexValues num error")
@@ -232,47 +403,246 @@ func (d *Dao) Upsert(data map[string]interface{}, indexValues ...interface{}) (m
 			pk = append(pk, index)
 		}
 	}
-	err = d.Session().runInTransaction(func() error {
-		model, err = d.Select(true, pk...)
-		if err == d.notFoundError {
-			model, err = d.Insert(data, indexValues...)
-		} else if err == nil {
-			_, err = model.Update(data)
+	var (
+		dialect   = d.Session().Dialect()
+		inserter  = d.BuildInsert().Values(data)
+		columns   = make([]string, 0, len(data))
+		autoIncr  = len(pk) == 0 && len(d.indexFields) == 1
+		returning = autoIncr && dialect.Supports(builder.FeatureReturning)
+	)
+	if len(conflict) == 0 {
+		conflict = d.indexFields
+	}
+	if !dialect.Supports(builder.FeatureOnDuplicateKey) {
+		// 自增主键不在插入的数据中，以其为冲突列永远不会冲突
+		for _, column := range conflict {
+			if _, ok := data[column]; !ok {
+				return nil, NewError(ModelRuntimeError, "dao.Upsert conflict column "+column+" not in data")
+			}
 		}
-		return err
-	})
-	return
-}
-
-func (d *Dao) Select(forUpdate bool, indexValues ...interface{}) (ModelIfe, error) {
-	if forUpdate {
-		where, err := d.buildWhere(indexValues...)
+	}
+	isConflict := func(column string) bool {
+		for _, c := range conflict {
+			if c == column {
+				return true
+			}
+		}
+		return false
+	}
+	for k := range data {
+		if !d.isIndexField(k) && !isConflict(k) {
+			columns = append(columns, k)
+		}
+	}
+	sort.Strings(columns)
+	switch {
+	case len(columns) > 0 || (autoIncr && !returning):
+		inserter.OnConflict(conflict...).OnDuplicateKeyUpdate(columns...)
+		if autoIncr && !returning {
+			// 冲突更新时LastInsertId返回已存在记录的自增主键
+			column := builder.QuoteIdentifier(d.indexFields[0])
+			inserter.OnDuplicateKeyUpdate(column + "=LAST_INSERT_ID(" + column + ")")
+		}
+	case returning:
+		// DO NOTHING时已存在的记录不会被RETURNING返回，以冲突列更新为自身使其返回
+		inserter.OnConflict(conflict...).OnDuplicateKeyUpdate(conflict[0])
+	case dialect.Supports(builder.FeatureOnDuplicateKey):
+		// 只有主键时不使用INSERT IGNORE，其会将外键、非空等错误也降级为警告
+		column := builder.QuoteIdentifier(d.indexFields[0])
+		inserter.OnDuplicateKeyUpdate(column + "=" + column)
+	default:
+		inserter.Ignore()
+	}
+	if returning {
+		inserter.Returning(d.indexFields[0])
+	}
+	query, params, err := inserter.Build()
+	if err != nil {
+		return nil, err
+	}
+	if returning {
+		rows, err := d.Session().queryWrite(query, params...)
 		if err != nil {
 			return nil, err
 		}
-		query, params, err := builder.Select().Table(d.GetTableName()).Columns(d.fields...).Where(where).Tail("FOR UPDATE").Build()
+		result, err := ResolveDataFromRows(rows)
 		if err != nil {
 			return nil, err
+		} else if len(result) != 1 {
+			return nil, NewError(ModelRuntimeError, "dao.Upsert error")
+		}
+		pk = append(pk, result[0][d.indexFields[0]])
+	} else {
+		result, err := d.ExecWithSql(query, params)
+		if err != nil {
+			return nil, err
+		}
+		if autoIncr {
+			id, err := result.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+			pk = append(pk, id)
 		}
+	}
+	return d.CreateObj(data, pk...)
+}
 
-		sess := d.Session()
-		sess.txMutex.RLock()
-		defer sess.txMutex.RUnlock()
+// UpdateWhere 按条件批量更新，返回影响的行数
+// 条件为单个主键时更新缓存的model，否则清除该表所有缓存的model
+func (d *Dao) UpdateWhere(set map[string]interface{}, where interface{}, opts ...Option) (int64, error) {
+	updater := d.BuildUpdate().Set(set).Where(where)
+	if !updater.HasWhere() && !fetchOption(opts...).allowAll {
+		return 0, ErrEmptyWhere
+	}
+	query, params, err := updater.Build()
+	if err != nil {
+		return 0, err
+	}
+	result, err := d.ExecWithSql(query, params)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		d.refreshCache(set, where)
+	}
+	return affected, nil
+}
 
-		if sess.tx == nil {
-			return nil, NewError(ModelRuntimeError, "Attempt to load for update out of transaction")
+// Increment 按条件将数值列原子地加上delta，delta为负数时减少
+func (d *Dao) Increment(column string, delta interface{}, where interface{}, opts ...Option) (int64, error) {
+	return d.UpdateWhere(map[string]interface{}{column: builder.Col(column).Add(delta)}, where, opts...)
+}
+
+// DeleteWhere 按条件批量删除，返回影响的行数
+func (d *Dao) DeleteWhere(where interface{}, opts ...Option) (int64, error) {
+	deleter := d.BuildDelete().Where(where)
+	if !deleter.HasWhere() && !fetchOption(opts...).allowAll {
+		return 0, ErrEmptyWhere
+	}
+	query, params, err := deleter.Build()
+	if err != nil {
+		return 0, err
+	}
+	result, err := d.ExecWithSql(query, params)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		if indexValues, ok := d.singleIndexValues(where); ok {
+			d.RemoveCache(indexValues...)
+		} else {
+			d.Session().daoModelCache.DelTable(d.Session().source, d.tableName)
 		}
-		row, err := sess.Query(query, params...)
-		if err != nil {
-			return nil, err
+	}
+	return affected, nil
+}
+
+// refreshCache 批量更新后更新或清除缓存的model
+func (d *Dao) refreshCache(set map[string]interface{}, where interface{}) {
+	indexValues, ok := d.singleIndexValues(where)
+	if !ok {
+		d.Session().daoModelCache.DelTable(d.Session().source, d.tableName)
+		return
+	}
+	// 表达式或修改主键时无法得知更新后的值
+	if hasExpression(set) {
+		d.RemoveCache(indexValues...)
+		return
+	}
+	for k := range set {
+		if d.isIndexField(k) {
+			d.RemoveCache(indexValues...)
+			return
 		}
-		ms, err := d.ResolveModelFromRows(row)
+	}
+	if model, err := d.QueryCache(indexValues...); err == nil {
+		if err = internal.ScanStruct(set, model, defaultTagName, true); err != nil {
+			d.RemoveCache(indexValues...)
+			return
+		}
+		d.SaveCache(model)
+	}
+}
+
+// hasExpression 更新的值是否包含表达式或子查询
+func hasExpression(set map[string]interface{}) bool {
+	for k, v := range set {
+		if strings.Contains(k, builder.PlaceHolder) {
+			return true
+		}
+		switch v.(type) {
+		case *builder.Column, *builder.Selector:
+			return true
+		}
+	}
+	return false
+}
+
+// singleIndexValues where条件为以各主键相等的map时返回对应的主键值
+func (d *Dao) singleIndexValues(where interface{}) ([]interface{}, bool) {
+	mp, ok := where.(map[string]interface{})
+	if !ok || len(mp) != len(d.indexFields) {
+		return nil, false
+	}
+	indexValues, ok := d.getIndexValuesFromData(mp)
+	if !ok {
+		return nil, false
+	}
+	for _, v := range indexValues {
+		if _, err := d.buildKey(v); err != nil {
+			return nil, false
+		}
+	}
+	return indexValues, true
+}
+
+// sameColumns 两条记录的列是否相同
+func sameColumns(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// copyData 复制写入的数据，避免修改调用方的map
+func copyData(data map[string]interface{}) map[string]interface{} {
+	dataCopy := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		dataCopy[k] = v
+	}
+	return dataCopy
+}
+
+func (d *Dao) isIndexField(field string) bool {
+	for _, index := range d.indexFields {
+		if field == index {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dao) Select(forUpdate bool, indexValues ...interface{}) (ModelIfe, error) {
+	if forUpdate {
+		where, err := d.buildWhere(indexValues...)
 		if err != nil {
 			return nil, err
-		} else if len(ms) < 1 {
-			return nil, d.notFoundError
 		}
-		return ms[0], nil
+		return d.SelectOne(where, ForUpdate())
 	}
 	if obj, err := d.QueryCache(indexValues...); err == nil {
 		return obj, nil
@@ -286,18 +656,25 @@ func (d *Dao) Select(forUpdate bool, indexValues ...interface{}) (ModelIfe, erro
 
 func (d *Dao) SelectById(id interface{}, opts ...Option) (ModelIfe, error) {
 	option := fetchOption(opts...)
-	model, err := d.Select(option.forUpdate, id)
+	if option.lock != builder.LockNone {
+		where, err := d.buildWhere(id)
+		if err != nil {
+			return nil, err
+		}
+		return d.SelectOne(where, opts...)
+	}
+	model, err := d.Select(false, id)
 	if err != nil {
 		return nil, err
 	}
-	if !option.forUpdate && (option.forceLoad || option.load) {
+	if option.forceLoad || option.load {
 		return model.Load(opts...)
 	}
 	return model, nil
 }
 
 func (d *Dao) SelectOne(where interface{}, opts ...Option) (ModelIfe, error) {
-	query, params, err := builder.Select().Table(d.GetTableName()).Columns(d.fields...).Where(where).Build()
+	query, params, err := d.BuildSelect().Columns(d.fields...).Where(where).Lock(fetchOption(opts...).lock).Build()
 	if err != nil {
 		return nil, err
 	}
@@ -319,7 +696,7 @@ func (d *Dao) SelectOneWithSql(query string, params []interface{}, opts ...Optio
 }
 
 func (d *Dao) SelectMulti(where interface{}, opts ...Option) ([]ModelIfe, error) {
-	query, params, err := builder.Select().Table(d.GetTableName()).Columns(d.fields...).Where(where).Build()
+	query, params, err := d.BuildSelect().Columns(d.fields...).Where(where).Lock(fetchOption(opts...).lock).Build()
 	if err != nil {
 		return nil, err
 	}
@@ -380,7 +757,7 @@ func (d *Dao) SelectChan(query string, params []interface{}, opts ...Option) (<-
 }
 
 func (d *Dao) GetCount(column string, where interface{}, opts ...Option) (int, error) {
-	query, params, err := builder.Select().Table(d.GetTableName()).FuncColumns(map[string]string{
+	query, params, err := d.BuildSelect().FuncColumns(map[string]string{
 		"c": fmt.Sprintf("COUNT(%s)", builder.QuoteIdentifier(column)),
 	}).Where(where).Build()
 	if err != nil {
@@ -401,7 +778,7 @@ func (d *Dao) GetCount(column string, where interface{}, opts ...Option) (int, e
 }
 
 func (d *Dao) GetSum(column string, where interface{}, opts ...Option) (int, error) {
-	query, params, err := builder.Select().Table(d.GetTableName()).FuncColumns(map[string]string{
+	query, params, err := d.BuildSelect().FuncColumns(map[string]string{
 		"s": fmt.Sprintf("SUM(%s)", builder.QuoteIdentifier(column)),
 	}).Where(where).Build()
 	if err != nil {
@@ -427,8 +804,18 @@ func (d *Dao) ExecWithSql(query string, params []interface{}) (sql.Result, error
 
 func (d *Dao) QueryWithSql(query string, params []interface{}, opts ...Option) (*sql.Rows, error) {
 	option := fetchOption(opts...)
-	replicaInstance := db.GetReplicaInstance()
-	if option.forceMaster || replicaInstance == nil {
+	if option.lock != builder.LockNone {
+		// 锁定读只能在事务中执行，且必须走主库
+		sess := d.Session()
+		sess.txMutex.RLock()
+		defer sess.txMutex.RUnlock()
+
+		if sess.tx == nil {
+			return nil, NewError(ModelRuntimeError, "Attempt to load with lock out of transaction")
+		}
+		return sess.Query(query, params...)
+	}
+	if option.forceMaster || !d.Session().hasReplica() || d.Session().pinnedToPrimary() {
 		return d.Session().Query(query, params...)
 	} else {
 		return d.Session().QueryReplica(query, params...)
@@ -436,19 +823,24 @@ func (d *Dao) QueryWithSql(query string, params []interface{}, opts ...Option) (
 }
 
 func (d *Dao) BuildSelect() *builder.Selector {
-	return builder.Select().Table(d.GetTableName())
+	return builder.Select().Dialect(d.Session().Dialect()).Table(d.GetTableName())
 }
 
 func (d *Dao) BuildUpdate() *builder.Updater {
-	return builder.Update().Table(d.GetTableName())
+	return builder.Update().Dialect(d.Session().Dialect()).Table(d.GetTableName())
+}
+
+// BuildUnion 以UNION组合多个查询，可继续调用UnionAll、Intersect、Except
+func (d *Dao) BuildUnion(selectors ...*builder.Selector) *builder.Compound {
+	return builder.Union(selectors...).Dialect(d.Session().Dialect())
 }
 
 func (d *Dao) BuildInsert() *builder.Inserter {
-	return builder.Insert().Table(d.GetTableName())
+	return builder.Insert().Dialect(d.Session().Dialect()).Table(d.GetTableName())
 }
 
 func (d *Dao) BuildDelete() *builder.Deleter {
-	return builder.Delete().Table(d.GetTableName())
+	return builder.Delete().Dialect(d.Session().Dialect()).Table(d.GetTableName())
 }
 
 func (d *Dao) ResolveChan(modelCh <-chan ModelIfe, errCh <-chan error, f func(model ModelIfe) error) error {
@@ -0,0 +1,373 @@
+package sorm
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+	"github.com/xkisas/sorm/db"
+)
+
+func TestDaoUpsert_MySQL(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "upsert_mysql", builder.MySQL)
+	defer db.Close("upsert_mysql")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	// 任一唯一键冲突时更新，LastInsertId返回已存在记录的主键
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`name`, `views`) VALUES(?,?) "+
+		"ON DUPLICATE KEY UPDATE `name`=VALUES(`name`), `views`=VALUES(`views`), `id`=LAST_INSERT_ID(`id`)")).
+		WithArgs("foo", 1).
+		WillReturnResult(sqlmock.NewResult(7, 2))
+	data := map[string]interface{}{"name": "foo", "views": 1}
+	model, err := dao.Upsert(data)
+	ass.Nil(err)
+	ass.Equal(7, model.(*testUser).Id)
+	ass.Equal("foo", model.(*testUser).Name)
+	ass.NotContains(data, "id")
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`id`, `name`) VALUES(?,?) "+
+		"ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)")).
+		WithArgs(3, "bar").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	model, err = dao.Upsert(map[string]interface{}{"name": "bar"}, 3)
+	ass.Nil(err)
+	ass.Equal(3, model.(*testUser).Id)
+
+	// 只有主键时以主键更新为自身，不使用INSERT IGNORE
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`id`) VALUES(?) ON DUPLICATE KEY UPDATE `id`=`id`")).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	model, err = dao.Upsert(map[string]interface{}{}, 4)
+	ass.Nil(err)
+	ass.Equal(4, model.(*testUser).Id)
+
+	// 外键等错误不会被忽略
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`id`) VALUES(?) ON DUPLICATE KEY UPDATE `id`=`id`")).
+		WithArgs(5).
+		WillReturnError(&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"})
+	_, err = dao.Upsert(map[string]interface{}{}, 5)
+	ass.True(errors.Is(err, ErrForeignKey))
+	_, err = dao.QueryCache(5)
+	ass.Equal(ModelNotFoundError, err)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestDaoUpsert_PostgreSQL(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "upsert_postgres", builder.PostgreSQL)
+	defer db.Close("upsert_postgres")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	// 自增主键不在数据中，需指定唯一键作为冲突列
+	_, err := dao.Upsert(map[string]interface{}{"name": "foo", "views": 1})
+	ass.NotNil(err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "test_user"("name", "views") VALUES($1,$2) `+
+		`ON CONFLICT ("name") DO UPDATE SET "views"=EXCLUDED."views" RETURNING "id"`)).
+		WithArgs("foo", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	model, err := dao.UpsertOn([]string{"name"}, map[string]interface{}{"name": "foo", "views": 1})
+	ass.Nil(err)
+	ass.Equal(7, model.(*testUser).Id)
+	ass.Equal(1, model.(*testUser).Views)
+
+	// 只有冲突列时以自身更新，已存在的记录也会被RETURNING返回
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "test_user"("name") VALUES($1) ` +
+		`ON CONFLICT ("name") DO UPDATE SET "name"=EXCLUDED."name" RETURNING "id"`)).
+		WithArgs("foo").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	model, err = dao.UpsertOn([]string{"name"}, map[string]interface{}{"name": "foo"})
+	ass.Nil(err)
+	ass.Equal(7, model.(*testUser).Id)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "test_user"("id", "name") VALUES($1,$2) `+
+		`ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name"`)).
+		WithArgs(3, "bar").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	model, err = dao.Upsert(map[string]interface{}{"name": "bar"}, 3)
+	ass.Nil(err)
+	ass.Equal(3, model.(*testUser).Id)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "test_user"("id") VALUES($1) ON CONFLICT DO NOTHING`)).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	model, err = dao.Upsert(map[string]interface{}{}, 4)
+	ass.Nil(err)
+	ass.Equal(4, model.(*testUser).Id)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestDaoUpsert_SQLite(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "upsert_sqlite", builder.SQLite)
+	defer db.Close("upsert_sqlite")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "test_user"("name", "views") VALUES(?,?) `+
+		`ON CONFLICT ("name") DO UPDATE SET "views"=EXCLUDED."views" RETURNING "id"`)).
+		WithArgs("foo", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	model, err := dao.UpsertOn([]string{"name"}, map[string]interface{}{"name": "foo", "views": 1})
+	ass.Nil(err)
+	ass.Equal(7, model.(*testUser).Id)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestDaoInsertMulti_MySQL(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "insert_multi_mysql", builder.MySQL)
+	defer db.Close("insert_multi_mysql")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	// 列不同的记录分为不同的语句，缺少的列不以NULL填充
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`name`, `views`) VALUES(?,?), (?,?)")).
+		WithArgs("a", 1, "b", 2).
+		WillReturnResult(sqlmock.NewResult(10, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`name`) VALUES(?)")).
+		WithArgs("c").
+		WillReturnResult(sqlmock.NewResult(12, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`name`, `views`) VALUES(?,?)")).
+		WithArgs("d", 4).
+		WillReturnResult(sqlmock.NewResult(13, 1))
+	mock.ExpectCommit()
+	data := []map[string]interface{}{
+		{"name": "a", "views": 1},
+		{"name": "b", "views": 2},
+		{"name": "c"},
+		{"name": "d", "views": 4},
+	}
+	models, err := dao.InsertMulti(data)
+	ass.Nil(err)
+	if ass.Len(models, 4) {
+		for i, id := range []int{10, 11, 12, 13} {
+			ass.Equal(id, models[i].(*testUser).Id)
+			ass.Equal(data[i]["name"], models[i].(*testUser).Name)
+		}
+	}
+	// 不修改调用方的map
+	for _, row := range data {
+		ass.NotContains(row, "id")
+	}
+	ass.Nil(mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`name`) VALUES(?), (?)")).
+		WithArgs("a", "b").
+		WillReturnResult(sqlmock.NewResult(20, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`name`) VALUES(?)")).
+		WithArgs("c").
+		WillReturnResult(sqlmock.NewResult(22, 1))
+	mock.ExpectCommit()
+	models, err = dao.InsertMulti([]map[string]interface{}{{"name": "a"}, {"name": "b"}, {"name": "c"}}, BatchSize(2))
+	ass.Nil(err)
+	if ass.Len(models, 3) {
+		ass.Equal(22, models[2].(*testUser).Id)
+	}
+	ass.Nil(mock.ExpectationsWereMet())
+
+	_, err = dao.InsertMulti([]map[string]interface{}{{"id": 1, "name": "a"}, {"name": "b"}})
+	ass.NotNil(err)
+}
+
+func TestDaoInsertMulti_PostgreSQL(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "insert_multi_postgres", builder.PostgreSQL)
+	defer db.Close("insert_multi_postgres")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "test_user"("name") VALUES($1), ($2) RETURNING "id"`)).
+		WithArgs("a", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(9))
+	mock.ExpectCommit()
+	data := []map[string]interface{}{{"name": "a"}, {"name": "b"}}
+	models, err := dao.InsertMulti(data)
+	ass.Nil(err)
+	if ass.Len(models, 2) {
+		ass.Equal(5, models[0].(*testUser).Id)
+		ass.Equal(9, models[1].(*testUser).Id)
+	}
+	ass.NotContains(data[0], "id")
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestDaoUpdateWhere(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "update_where", builder.MySQL)
+	defer db.Close("update_where")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+	cache := func(id int) ModelIfe {
+		model, err := dao.CreateObj(map[string]interface{}{"id": id, "name": "foo", "views": 10})
+		ass.Nil(err)
+		return model
+	}
+	cached := func(id int) bool {
+		_, err := dao.QueryCache(id)
+		return err == nil
+	}
+
+	// where为空时需AllowAll
+	_, err := dao.UpdateWhere(map[string]interface{}{"views": 0}, nil)
+	ass.Equal(ErrEmptyWhere, err)
+	_, err = dao.UpdateWhere(map[string]interface{}{"views": 0}, map[string]interface{}{})
+	ass.Equal(ErrEmptyWhere, err)
+	cache(1)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=?")).
+		WithArgs(0).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	affected, err := dao.UpdateWhere(map[string]interface{}{"views": 0}, nil, AllowAll())
+	ass.Nil(err)
+	ass.Equal(int64(3), affected)
+	ass.False(cached(1))
+
+	// 单个主键的条件直接更新缓存的model
+	model := cache(1)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `name`=? WHERE `id`=?")).
+		WithArgs("bar", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = dao.UpdateWhere(map[string]interface{}{"name": "bar"}, map[string]interface{}{"id": 1})
+	ass.Nil(err)
+	ass.True(cached(1))
+	ass.Equal("bar", model.(*testUser).Name)
+	ass.Equal(10, model.(*testUser).Views)
+
+	// 表达式无法得知更新后的值，清除缓存
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=`views`+? WHERE `id`=?")).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = dao.Increment("views", 1, map[string]interface{}{"id": 1})
+	ass.Nil(err)
+	ass.False(cached(1))
+
+	// 修改主键时清除原主键的缓存
+	cache(1)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `id`=? WHERE `id`=?")).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = dao.UpdateWhere(map[string]interface{}{"id": 2}, map[string]interface{}{"id": 1})
+	ass.Nil(err)
+	ass.False(cached(1))
+	ass.False(cached(2))
+
+	// 非主键条件清除整张表的缓存
+	cache(1)
+	cache(2)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=? WHERE `name`=?")).
+		WithArgs(0, "foo").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	_, err = dao.UpdateWhere(map[string]interface{}{"views": 0}, map[string]interface{}{"name": "foo"})
+	ass.Nil(err)
+	ass.False(cached(1))
+	ass.False(cached(2))
+
+	// 未影响任何记录时保留缓存
+	cache(1)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=? WHERE `name`=?")).
+		WithArgs(0, "none").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	_, err = dao.UpdateWhere(map[string]interface{}{"views": 0}, map[string]interface{}{"name": "none"})
+	ass.Nil(err)
+	ass.True(cached(1))
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestDaoDeleteWhere(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "delete_where", builder.MySQL)
+	defer db.Close("delete_where")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+	cache := func(ids ...int) {
+		for _, id := range ids {
+			_, err := dao.CreateObj(map[string]interface{}{"id": id, "name": "foo", "views": 10})
+			ass.Nil(err)
+		}
+	}
+	cached := func(id int) bool {
+		_, err := dao.QueryCache(id)
+		return err == nil
+	}
+
+	_, err := dao.DeleteWhere(nil)
+	ass.Equal(ErrEmptyWhere, err)
+
+	cache(1, 2)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `test_user` WHERE `id`=?")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = dao.DeleteWhere(map[string]interface{}{"id": 1})
+	ass.Nil(err)
+	ass.False(cached(1))
+	ass.True(cached(2))
+
+	cache(1)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `test_user` WHERE `views`>?")).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	_, err = dao.DeleteWhere(builder.EmptyClause().GreaterThan("views", 5))
+	ass.Nil(err)
+	ass.False(cached(1))
+	ass.False(cached(2))
+
+	cache(1)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `test_user`")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = dao.DeleteWhere(nil, AllowAll())
+	ass.Nil(err)
+	ass.False(cached(1))
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestDaoSelect_Lock(t *testing.T) {
+	ass := assert.New(t)
+	db.DefaultHealthCheckInterval = 0
+	primary, primaryMock, _ := sqlmock.New()
+	replica, replicaMock, _ := sqlmock.New()
+	ass.Nil(db.RegisterDB("select_lock", primary, builder.MySQL))
+	defer db.Close("select_lock")
+	ass.Nil(db.RegisterReplicaDB("select_lock", replica, 1))
+	sess := NewSession(context.TODO()).Use("select_lock")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	// 锁定读只能在事务中执行
+	_, err := dao.SelectOne(map[string]interface{}{"name": "foo"}, ForUpdateSkipLocked())
+	if ass.NotNil(err) {
+		ass.Contains(err.Error(), "out of transaction")
+	}
+	_, err = dao.SelectById(1, Lock(builder.LockForShareNoWait))
+	if ass.NotNil(err) {
+		ass.Contains(err.Error(), "out of transaction")
+	}
+
+	// 事务中的锁定读走主库
+	columns := []string{"id", "name", "views"}
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery(regexp.QuoteMeta("FROM `test_user` WHERE `name`=? FOR UPDATE SKIP LOCKED")).
+		WithArgs("foo").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "foo", 3))
+	primaryMock.ExpectQuery(regexp.QuoteMeta("FROM `test_user` WHERE `id`=? FOR SHARE NOWAIT")).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "bar", 4))
+	primaryMock.ExpectCommit()
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		model, err := dao.SelectOne(map[string]interface{}{"name": "foo"}, ForUpdateSkipLocked())
+		if err != nil {
+			return err
+		}
+		ass.Equal(3, model.(*testUser).Views)
+		model, err = dao.SelectById(2, Lock(builder.LockForShareNoWait))
+		if err != nil {
+			return err
+		}
+		ass.Equal("bar", model.(*testUser).Name)
+		return nil
+	}))
+	ass.Nil(primaryMock.ExpectationsWereMet())
+	ass.Nil(replicaMock.ExpectationsWereMet())
+}
@@ -2,17 +2,24 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"sync"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/xkisas/sorm/builder"
 )
 
-const driverName = "mysql"
+const (
+	DefaultName       = "default"
+	defaultDriverName = "mysql"
+)
 
 var (
-	dbInstance        *sql.DB
-	dbInstanceReplica *sql.DB
+	ErrDataSourceNotFound = errors.New("[db] data source not found")
+	ErrDataSourceExists   = errors.New("[db] data source already exists")
+	ErrDSNRequired        = errors.New("[db] dsn is required for non mysql driver")
+	ErrDialectNotFound    = errors.New("[db] dialect not found")
 )
 
 type Conf struct {
@@ -21,8 +28,33 @@ type Conf struct {
 	Password string
 	Host     string
 	Port     int
+
+	Driver  string          // 驱动名，默认为mysql
+	DSN     string          // 若不为空则直接使用，忽略上面的连接信息
+	Dialect builder.Dialect // 为空时根据驱动名获取
+	Weight  int             // 从库权重，仅对Weighted策略生效
+}
+
+// DataSource 一个具名数据源，包含主库及可选的从库
+type DataSource struct {
+	name       string
+	driverName string
+	dialect    builder.Dialect
+	db         *sql.DB
+
+	replicas      []*Replica
+	replicaIndex  uint64
+	policy        Policy
+	checker       *healthChecker
+	replicaLocker sync.RWMutex
+	weightLocker  sync.Mutex
 }
 
+var (
+	dataSources       = make(map[string]*DataSource)
+	dataSourcesLocker sync.RWMutex
+)
+
 func buildDSN(conf Conf, opts []Option) string {
 	mysqlConf := mysql.NewConfig()
 	mysqlConf.DBName = conf.Name
@@ -41,39 +73,207 @@ func buildDSN(conf Conf, opts []Option) string {
 	return mysqlConf.FormatDSN()
 }
 
-func Setup(conf Conf, opts ...Option) {
-	if dbInstance == nil {
-		var err error
-		dbInstance, err = sql.Open(driverName, buildDSN(conf, opts))
-		if err == nil {
-			if err = dbInstance.Ping(); err == nil {
-				return
-			}
+func open(conf Conf, opts []Option) (*sql.DB, string, builder.Dialect, error) {
+	driverName := conf.Driver
+	if driverName == "" {
+		driverName = defaultDriverName
+	}
+	dialect := conf.Dialect
+	if dialect == nil {
+		var ok bool
+		if dialect, ok = builder.GetDialect(driverName); !ok {
+			return nil, "", nil, ErrDialectNotFound
+		}
+	}
+	dsn := conf.DSN
+	if dsn == "" {
+		if driverName != defaultDriverName {
+			return nil, "", nil, ErrDSNRequired
 		}
-		log.Fatalln("dbHelper.DbInstance,", err)
+		dsn = buildDSN(conf, opts)
+	}
+	instance, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err = instance.Ping(); err != nil {
+		instance.Close()
+		return nil, "", nil, err
+	}
+	return instance, driverName, dialect, nil
+}
+
+// Register 注册具名数据源，连接及Ping在加锁前完成，避免阻塞其他数据源的获取
+func Register(name string, conf Conf, opts ...Option) error {
+	if _, err := Get(name); err == nil {
+		return ErrDataSourceExists
+	}
+	instance, driverName, dialect, err := open(conf, opts)
+	if err != nil {
+		return err
+	}
+	dataSourcesLocker.Lock()
+	defer dataSourcesLocker.Unlock()
+	if _, ok := dataSources[name]; ok {
+		instance.Close()
+		return ErrDataSourceExists
+	}
+	dataSources[name] = &DataSource{
+		name:       name,
+		driverName: driverName,
+		dialect:    dialect,
+		db:         instance,
+	}
+	return nil
+}
+
+// RegisterDB 使用已打开的连接注册具名数据源
+func RegisterDB(name string, instance *sql.DB, dialect builder.Dialect) error {
+	dataSourcesLocker.Lock()
+	defer dataSourcesLocker.Unlock()
+	if _, ok := dataSources[name]; ok {
+		return ErrDataSourceExists
+	}
+	if dialect == nil {
+		dialect = builder.DefaultDialect()
+	}
+	dataSources[name] = &DataSource{
+		name:    name,
+		dialect: dialect,
+		db:      instance,
+	}
+	return nil
+}
+
+// RegisterReplica 为具名数据源添加一个从库，可多次调用注册多个从库
+func RegisterReplica(name string, conf Conf, opts ...Option) error {
+	ds, err := Get(name)
+	if err != nil {
+		return err
+	}
+	if conf.Driver == "" {
+		conf.Driver = ds.driverName
+	}
+	instance, _, _, err := open(conf, opts)
+	if err != nil {
+		return err
+	}
+	ds.addReplica(newReplica(instance, conf.Weight))
+	return nil
+}
+
+// RegisterReplicaDB 使用已打开的连接为具名数据源添加从库
+func RegisterReplicaDB(name string, instance *sql.DB, weight int) error {
+	ds, err := Get(name)
+	if err != nil {
+		return err
+	}
+	ds.addReplica(newReplica(instance, weight))
+	return nil
+}
+
+func Setup(conf Conf, opts ...Option) error {
+	return Register(DefaultName, conf, opts...)
+}
+
+func SetupReplica(conf Conf, opts ...Option) error {
+	return RegisterReplica(DefaultName, conf, opts...)
+}
+
+// Get 获取具名数据源
+func Get(name string) (*DataSource, error) {
+	dataSourcesLocker.RLock()
+	defer dataSourcesLocker.RUnlock()
+	if ds, ok := dataSources[name]; ok {
+		return ds, nil
 	}
+	return nil, ErrDataSourceNotFound
 }
 
-func SetupReplica(conf Conf, opts ...Option) {
-	if dbInstanceReplica == nil {
-		var err error
-		dbInstanceReplica, err = sql.Open(driverName, buildDSN(conf, opts))
-		if err == nil {
-			if err = dbInstanceReplica.Ping(); err == nil {
-				return
-			}
+// Close 关闭并移除具名数据源
+func Close(name string) error {
+	dataSourcesLocker.Lock()
+	ds, ok := dataSources[name]
+	delete(dataSources, name)
+	dataSourcesLocker.Unlock()
+	if !ok {
+		return ErrDataSourceNotFound
+	}
+	return ds.close()
+}
+
+func CloseAll() error {
+	dataSourcesLocker.Lock()
+	sources := dataSources
+	dataSources = make(map[string]*DataSource)
+	dataSourcesLocker.Unlock()
+	var err error
+	for _, ds := range sources {
+		if e := ds.close(); e != nil && err == nil {
+			err = e
 		}
-		log.Fatalln("dbHelper.DbInstanceReplica,", err)
 	}
+	return err
 }
 
 func GetInstance() *sql.DB {
-	if dbInstance == nil {
-		log.Fatalln("dbHelper.DbInstance, dbInstance is nil")
+	if ds, err := Get(DefaultName); err == nil {
+		return ds.DB()
 	}
-	return dbInstance
+	return nil
 }
 
 func GetReplicaInstance() *sql.DB {
-	return dbInstanceReplica
+	if ds, err := Get(DefaultName); err == nil {
+		return ds.Replica()
+	}
+	return nil
+}
+
+// SetPolicy 设置具名数据源的从库选择策略
+func SetPolicy(name string, policy Policy) error {
+	ds, err := Get(name)
+	if err != nil {
+		return err
+	}
+	ds.SetPolicy(policy)
+	return nil
+}
+
+func (ds *DataSource) Name() string {
+	return ds.name
+}
+
+func (ds *DataSource) DriverName() string {
+	return ds.driverName
+}
+
+func (ds *DataSource) Dialect() builder.Dialect {
+	return ds.dialect
+}
+
+func (ds *DataSource) DB() *sql.DB {
+	return ds.db
+}
+
+// Replica 按选择策略返回一个健康的从库，无可用从库时返回nil
+func (ds *DataSource) Replica() *sql.DB {
+	if replica := ds.PickReplica(); replica != nil {
+		return replica.DB()
+	}
+	return nil
+}
+
+func (ds *DataSource) close() error {
+	ds.HealthCheck(0)
+	var err error
+	for _, replica := range ds.Replicas() {
+		if e := replica.DB().Close(); e != nil {
+			err = e
+		}
+	}
+	if e := ds.db.Close(); e != nil {
+		err = e
+	}
+	return err
 }
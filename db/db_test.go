@@ -0,0 +1,59 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+)
+
+// blockingDriver 建立连接时阻塞，直到release被关闭
+type blockingDriver struct {
+	opened  chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDriver) Open(string) (driver.Conn, error) {
+	d.opened <- struct{}{}
+	<-d.release
+	return nil, errors.New("unreachable")
+}
+
+func TestRegister_DoesNotBlockGet(t *testing.T) {
+	ass := assert.New(t)
+	drv := &blockingDriver{opened: make(chan struct{}, 1), release: make(chan struct{})}
+	sql.Register("db_test_blocking", drv)
+	ass.Nil(RegisterDB("db_test_fast", &sql.DB{}, builder.MySQL))
+	defer func() {
+		dataSourcesLocker.Lock()
+		delete(dataSources, "db_test_fast")
+		dataSourcesLocker.Unlock()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Register("db_test_slow", Conf{Driver: "db_test_blocking", DSN: "slow", Dialect: builder.MySQL})
+	}()
+	<-drv.opened
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := Get("db_test_fast")
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		ass.Nil(err)
+	case <-time.After(time.Second):
+		t.Fatal("Get blocked by a pending Register")
+	}
+
+	close(drv.release)
+	ass.NotNil(<-done)
+	_, err := Get("db_test_slow")
+	ass.Equal(ErrDataSourceNotFound, err)
+}
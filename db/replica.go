@@ -0,0 +1,206 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+type Policy int
+
+const (
+	RoundRobin    Policy = iota // 轮询
+	Weighted                    // 平滑加权轮询
+	LeastInFlight               // 选择正在使用连接数最少的从库
+)
+
+var DefaultHealthCheckInterval = 5 * time.Second
+
+type Replica struct {
+	db            *sql.DB
+	weight        int
+	currentWeight int
+	healthy       int32
+}
+
+func newReplica(instance *sql.DB, weight int) *Replica {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &Replica{db: instance, weight: weight, healthy: 1}
+}
+
+func (r *Replica) DB() *sql.DB {
+	return r.db
+}
+
+func (r *Replica) Weight() int {
+	return r.weight
+}
+
+func (r *Replica) Healthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// MarkDown 将从库移出轮询，待健康检查通过后恢复
+func (r *Replica) MarkDown() {
+	atomic.StoreInt32(&r.healthy, 0)
+}
+
+func (r *Replica) markUp() {
+	atomic.StoreInt32(&r.healthy, 1)
+}
+
+// 从库健康检查
+type healthChecker struct {
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (ds *DataSource) addReplica(replica *Replica) {
+	ds.replicaLocker.Lock()
+	defer ds.replicaLocker.Unlock()
+	ds.replicas = append(ds.replicas, replica)
+	if ds.checker == nil && DefaultHealthCheckInterval > 0 {
+		ds.startHealthCheck(DefaultHealthCheckInterval)
+	}
+}
+
+// Replicas 返回所有已注册的从库
+func (ds *DataSource) Replicas() []*Replica {
+	ds.replicaLocker.RLock()
+	defer ds.replicaLocker.RUnlock()
+	replicas := make([]*Replica, len(ds.replicas))
+	copy(replicas, ds.replicas)
+	return replicas
+}
+
+func (ds *DataSource) HasReplica() bool {
+	ds.replicaLocker.RLock()
+	defer ds.replicaLocker.RUnlock()
+	return len(ds.replicas) > 0
+}
+
+func (ds *DataSource) SetPolicy(policy Policy) {
+	ds.replicaLocker.Lock()
+	defer ds.replicaLocker.Unlock()
+	ds.policy = policy
+}
+
+// PickReplica 按选择策略从健康的从库中选出一个，全部不可用时返回nil
+func (ds *DataSource) PickReplica() *Replica {
+	ds.replicaLocker.RLock()
+	var (
+		policy   = ds.policy
+		replicas = make([]*Replica, 0, len(ds.replicas))
+	)
+	for _, r := range ds.replicas {
+		if r.Healthy() {
+			replicas = append(replicas, r)
+		}
+	}
+	ds.replicaLocker.RUnlock()
+	if len(replicas) == 0 {
+		return nil
+	}
+	switch policy {
+	case Weighted:
+		return ds.pickWeighted(replicas)
+	case LeastInFlight:
+		return pickLeastInFlight(replicas)
+	default:
+		return ds.pickRoundRobin(replicas)
+	}
+}
+
+func (ds *DataSource) pickRoundRobin(replicas []*Replica) *Replica {
+	index := atomic.AddUint64(&ds.replicaIndex, 1)
+	return replicas[index%uint64(len(replicas))]
+}
+
+// pickLeastInFlight 选择使用中连接数最少的从库，数量相同时取靠前的
+func pickLeastInFlight(replicas []*Replica) *Replica {
+	var (
+		picked   *Replica
+		minInUse = -1
+	)
+	for _, r := range replicas {
+		if inUse := r.db.Stats().InUse; minInUse < 0 || inUse < minInUse {
+			picked, minInUse = r, inUse
+		}
+	}
+	return picked
+}
+
+func (ds *DataSource) pickWeighted(replicas []*Replica) *Replica {
+	ds.weightLocker.Lock()
+	defer ds.weightLocker.Unlock()
+	var (
+		picked *Replica
+		total  int
+	)
+	for _, r := range replicas {
+		r.currentWeight += r.weight
+		total += r.weight
+		if picked == nil || r.currentWeight > picked.currentWeight {
+			picked = r
+		}
+	}
+	picked.currentWeight -= total
+	return picked
+}
+
+// HealthCheck 调整从库健康检查的间隔，interval<=0时停止检查
+func (ds *DataSource) HealthCheck(interval time.Duration) {
+	ds.replicaLocker.Lock()
+	checker := ds.checker
+	ds.checker = nil
+	if interval > 0 {
+		ds.startHealthCheck(interval)
+	}
+	ds.replicaLocker.Unlock()
+	checker.shutdown()
+}
+
+func (ds *DataSource) startHealthCheck(interval time.Duration) {
+	checker := &healthChecker{
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	ds.checker = checker
+	go func() {
+		defer close(checker.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-checker.stop:
+				return
+			case <-ticker.C:
+				ds.checkReplicas(interval)
+			}
+		}
+	}()
+}
+
+func (c *healthChecker) shutdown() {
+	if c != nil {
+		close(c.stop)
+		<-c.done
+	}
+}
+
+func (ds *DataSource) checkReplicas(timeout time.Duration) {
+	for _, r := range ds.Replicas() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := r.db.PingContext(ctx); err != nil {
+			r.MarkDown()
+		} else {
+			r.markUp()
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) {
+	return stubConn{}, nil
+}
+
+type stubConn struct{}
+
+func (stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (stubConn) Close() error {
+	return nil
+}
+
+func (stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func init() {
+	sql.Register("db_test_stub", stubDriver{})
+}
+
+func newStubDB(t *testing.T) *sql.DB {
+	instance, err := sql.Open("db_test_stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instance
+}
+
+func newTestDataSource(t *testing.T, policy Policy, weights ...int) *DataSource {
+	ds := &DataSource{name: "test", db: newStubDB(t), policy: policy}
+	for _, weight := range weights {
+		ds.replicas = append(ds.replicas, newReplica(newStubDB(t), weight))
+	}
+	return ds
+}
+
+func indexOf(ds *DataSource, replica *Replica) int {
+	for i, r := range ds.replicas {
+		if r == replica {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestPickReplica_Weighted(t *testing.T) {
+	ass := assert.New(t)
+	ds := newTestDataSource(t, Weighted, 5, 1, 0)
+	ass.Equal(1, ds.replicas[2].Weight())
+
+	var (
+		counts   = make([]int, 3)
+		sequence = make([]int, 0, 7)
+	)
+	for i := 0; i < 70; i++ {
+		picked := indexOf(ds, ds.PickReplica())
+		counts[picked]++
+		if i < 7 {
+			sequence = append(sequence, picked)
+		}
+	}
+	ass.Equal([]int{50, 10, 10}, counts)
+	// 平滑加权，权重大的从库不会被连续选中太多次
+	ass.Equal([]int{0, 0, 1, 0, 2, 0, 0}, sequence)
+}
+
+func TestPickReplica_RoundRobin(t *testing.T) {
+	ass := assert.New(t)
+	ds := newTestDataSource(t, RoundRobin, 1, 1, 1)
+
+	picks := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		picks = append(picks, indexOf(ds, ds.PickReplica()))
+	}
+	ass.Equal([]int{1, 2, 0, 1}, picks)
+
+	// 计数溢出后从第一个从库重新开始
+	ds.replicaIndex = math.MaxUint64
+	picks = picks[:0]
+	for i := 0; i < 4; i++ {
+		picks = append(picks, indexOf(ds, ds.PickReplica()))
+	}
+	ass.Equal([]int{0, 1, 2, 0}, picks)
+}
+
+func TestPickReplica_LeastInFlight(t *testing.T) {
+	ass := assert.New(t)
+	ds := newTestDataSource(t, LeastInFlight, 1, 1, 1)
+
+	// 使用中的连接数相同时取靠前的从库
+	ass.Equal(0, indexOf(ds, ds.PickReplica()))
+
+	conn, err := ds.replicas[0].DB().Conn(context.Background())
+	ass.Nil(err)
+	defer conn.Close()
+	ass.Equal(1, indexOf(ds, ds.PickReplica()))
+
+	conn2, err := ds.replicas[1].DB().Conn(context.Background())
+	ass.Nil(err)
+	defer conn2.Close()
+	ass.Equal(2, indexOf(ds, ds.PickReplica()))
+}
+
+func TestPickReplica_AllDown(t *testing.T) {
+	ass := assert.New(t)
+	ds := newTestDataSource(t, RoundRobin, 1, 1)
+
+	ds.replicas[0].MarkDown()
+	for i := 0; i < 3; i++ {
+		ass.Equal(1, indexOf(ds, ds.PickReplica()))
+	}
+
+	ds.replicas[1].MarkDown()
+	ass.True(ds.HasReplica())
+	ass.Nil(ds.PickReplica())
+	ass.Nil(ds.Replica())
+
+	ds.replicas[0].markUp()
+	ass.Equal(0, indexOf(ds, ds.PickReplica()))
+}
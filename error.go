@@ -1,6 +1,45 @@
 package sorm
 
-import "errors"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	mysqlErrTooManyConnections = 1040
+	mysqlErrServerShutdown     = 1053
+	mysqlErrDupEntry           = 1062
+	mysqlErrLockWaitTimeout    = 1205
+	mysqlErrDeadlock           = 1213
+	mysqlErrNoReferencedRow    = 1216
+	mysqlErrRowIsReferenced    = 1217
+	mysqlErrDataTooLong        = 1406
+	mysqlErrRowIsReferenced2   = 1451
+	mysqlErrNoReferencedRow2   = 1452
+)
+
+var (
+	ErrDuplicateKey    = errors.New("duplicate key")
+	ErrForeignKey      = errors.New("foreign key constraint fails")
+	ErrDeadlock        = errors.New("deadlock")
+	ErrLockWaitTimeout = errors.New("lock wait timeout")
+	ErrDataTooLong     = errors.New("data too long")
+	ErrConnection      = errors.New("connection error")
+)
+
+var (
+	duplicateKeyRegexp = regexp.MustCompile("for key '([^']+)'")
+	foreignKeyRegexp   = regexp.MustCompile("\\(`([^`]+)`\\.`([^`]+)`, CONSTRAINT `([^`]+)`")
+	dataTooLongRegexp  = regexp.MustCompile("for column '([^']+)'")
+)
 
 type Error struct {
 	err error
@@ -38,3 +77,119 @@ func TryCatch(try func(), catch func(err error), errs ...error) {
 	}()
 	try()
 }
+
+// DBError 分类后的数据库错误，可通过errors.Is判断类别，Unwrap得到驱动返回的原始错误
+type DBError struct {
+	Kind  error  // ErrDuplicateKey、ErrForeignKey等
+	Key   string // 违反的唯一键、外键约束名，或超长的字段名
+	Table string
+	SQL   string
+	Err   error
+}
+
+func (e *DBError) Error() string {
+	var str = strings.Builder{}
+	str.WriteString(e.Kind.Error())
+	if e.Key != "" {
+		str.WriteString(fmt.Sprintf(" '%s'", e.Key))
+	}
+	if e.Table != "" {
+		str.WriteString(fmt.Sprintf(" on table `%s`", e.Table))
+	}
+	str.WriteString(": ")
+	str.WriteString(e.Err.Error())
+	return str.String()
+}
+
+func (e *DBError) Is(target error) bool {
+	return e.Kind == target
+}
+
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// errorKind 返回错误对应的类别，无法分类时返回nil
+func errorKind(err error) error {
+	switch mysqlErrorNumber(err) {
+	case mysqlErrDupEntry:
+		return ErrDuplicateKey
+	case mysqlErrNoReferencedRow, mysqlErrRowIsReferenced, mysqlErrRowIsReferenced2, mysqlErrNoReferencedRow2:
+		return ErrForeignKey
+	case mysqlErrDeadlock:
+		return ErrDeadlock
+	case mysqlErrLockWaitTimeout:
+		return ErrLockWaitTimeout
+	case mysqlErrDataTooLong:
+		return ErrDataTooLong
+	case mysqlErrTooManyConnections, mysqlErrServerShutdown:
+		return ErrConnection
+	}
+	// context.DeadlineExceeded也实现了net.Error，需先排除
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return ErrConnection
+	}
+	return nil
+}
+
+// wrapError 将驱动返回的错误包装为DBError，无法分类的错误原样返回
+func wrapError(err error, query string) error {
+	var dbErr *DBError
+	if err == nil || errors.As(err, &dbErr) {
+		return err
+	}
+	kind := errorKind(err)
+	if kind == nil {
+		return err
+	}
+	dbErr = &DBError{Kind: kind, SQL: query, Err: err}
+	_, dbErr.Table = parseStatement(query)
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return dbErr
+	}
+	switch kind {
+	case ErrDuplicateKey:
+		if match := duplicateKeyRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+			// MySQL 8.0起索引名带有表名前缀
+			dbErr.Key = match[1]
+			if i := strings.LastIndexByte(match[1], '.'); i >= 0 {
+				dbErr.Table, dbErr.Key = match[1][:i], match[1][i+1:]
+			}
+		}
+	case ErrForeignKey:
+		if match := foreignKeyRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+			dbErr.Table, dbErr.Key = match[2], match[3]
+		}
+	case ErrDataTooLong:
+		if match := dataTooLongRegexp.FindStringSubmatch(mysqlErr.Message); match != nil {
+			dbErr.Key = match[1]
+		}
+	}
+	return dbErr
+}
+
+func mysqlErrorNumber(err error) uint16 {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number
+	}
+	return 0
+}
+
+func IsDeadlock(err error) bool {
+	return mysqlErrorNumber(err) == mysqlErrDeadlock
+}
+
+func IsLockWaitTimeout(err error) bool {
+	return mysqlErrorNumber(err) == mysqlErrLockWaitTimeout
+}
+
+// IsRetryableError 是否为可通过重新执行事务解决的错误
+func IsRetryableError(err error) bool {
+	return IsDeadlock(err) || IsLockWaitTimeout(err)
+}
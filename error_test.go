@@ -0,0 +1,98 @@
+package sorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapError(t *testing.T) {
+	ass := assert.New(t)
+	tests := []struct {
+		err   error
+		query string
+		kind  error
+		key   string
+		table string
+	}{
+		// MySQL 5.7
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'foo' for key 'uk_name'"},
+			"INSERT INTO `user`(`name`) VALUES(?)", ErrDuplicateKey, "uk_name", "user"},
+		// MySQL 8.0起索引名带有表名前缀
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'foo' for key 'user.uk_name'"},
+			"INSERT INTO `user`(`name`) VALUES(?)", ErrDuplicateKey, "uk_name", "user"},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"},
+			"UPDATE `user` SET `id` = ?", ErrDuplicateKey, "PRIMARY", "user"},
+		{&mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: a foreign key constraint fails (`db`.`order`, CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`))"},
+			"DELETE FROM `user` WHERE `id` = ?", ErrForeignKey, "fk_user", "order"},
+		{&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`db`.`order`, CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`))"},
+			"INSERT INTO `order`(`user_id`) VALUES(?)", ErrForeignKey, "fk_user", "order"},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"},
+			"UPDATE `user` SET `name` = ?", ErrDeadlock, "", "user"},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"},
+			"SELECT * FROM `user` FOR UPDATE", ErrLockWaitTimeout, "", "user"},
+		{&mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name' at row 1"},
+			"INSERT INTO `user`(`name`) VALUES(?)", ErrDataTooLong, "name", "user"},
+		{&mysql.MySQLError{Number: 1040, Message: "Too many connections"},
+			"SELECT 1", ErrConnection, "", ""},
+		{driver.ErrBadConn, "SELECT * FROM `user`", ErrConnection, "", "user"},
+	}
+	for _, test := range tests {
+		err := wrapError(test.err, test.query)
+		ass.Equal(test.kind, errorKind(test.err), test.err.Error())
+		var dbErr *DBError
+		if !ass.True(errors.As(err, &dbErr), test.err.Error()) {
+			continue
+		}
+		ass.Equal(test.kind, dbErr.Kind)
+		ass.Equal(test.key, dbErr.Key, test.err.Error())
+		ass.Equal(test.table, dbErr.Table, test.err.Error())
+		ass.Equal(test.query, dbErr.SQL)
+		ass.True(errors.Is(err, test.kind))
+		ass.Equal(test.err, errors.Unwrap(err))
+		ass.True(errors.Is(err, test.err))
+	}
+}
+
+func TestWrapError_Passthrough(t *testing.T) {
+	ass := assert.New(t)
+	ass.Nil(wrapError(nil, "SELECT 1"))
+	// 无法分类的错误原样返回
+	other := &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+	ass.Nil(errorKind(other))
+	ass.Equal(error(other), wrapError(other, "SELECT"))
+	// 超时及取消不属于连接错误
+	for _, err := range []error{context.DeadlineExceeded, context.Canceled, fmt.Errorf("query: %w", context.DeadlineExceeded)} {
+		ass.Nil(errorKind(err))
+		ass.Equal(err, wrapError(err, "SELECT 1"))
+		ass.False(errors.Is(wrapError(err, "SELECT 1"), ErrConnection))
+		ass.Equal("context", errorClass(err))
+	}
+	plain := errors.New("plain")
+	ass.Equal(plain, wrapError(plain, "SELECT 1"))
+	// 已包装过的错误不重复包装
+	wrapped := wrapError(&mysql.MySQLError{Number: 1213}, "UPDATE `user` SET `a` = 1")
+	ass.Equal(wrapped, wrapError(wrapped, "COMMIT"))
+	outer := fmt.Errorf("tx: %w", wrapped)
+	ass.Equal(outer, wrapError(outer, "COMMIT"))
+}
+
+func TestDBError_As(t *testing.T) {
+	ass := assert.New(t)
+	origin := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'foo' for key 'user.uk_name'"}
+	err := fmt.Errorf("create user: %w", wrapError(origin, "INSERT INTO `user`(`name`) VALUES(?)"))
+	ass.True(errors.Is(err, ErrDuplicateKey))
+	ass.False(errors.Is(err, ErrForeignKey))
+	var dbErr *DBError
+	ass.True(errors.As(err, &dbErr))
+	ass.Equal("uk_name", dbErr.Key)
+	var mysqlErr *mysql.MySQLError
+	ass.True(errors.As(err, &mysqlErr))
+	ass.Equal(origin, mysqlErr)
+	ass.Equal("duplicate key 'uk_name' on table `user`: "+origin.Error(), dbErr.Error())
+}
@@ -44,7 +44,7 @@ func (td *TestDao) Count() (int, error) {
 }
 
 func setupDb() {
-	db.Setup(
+	err := db.Setup(
 		db.Conf{
 			Name:     "db",
 			User:     "root",
@@ -58,6 +58,9 @@ func setupDb() {
 		db.InterpolateParams(true),
 		db.Param("charset", "utf8"),
 	)
+	if err != nil {
+		panic(err)
+	}
 
 	fmt.Println("建立连接成功")
 }
@@ -3,6 +3,7 @@ module github.com/xkisas/sorm
 go 1.13
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/json-iterator/go v1.1.9
 	github.com/stretchr/testify v1.4.0
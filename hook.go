@@ -0,0 +1,108 @@
+package sorm
+
+import "sync"
+
+// 事务钩子，按事务层级记录，在最外层事务结束后执行
+type txHookLevel struct {
+	onCommit   []func()
+	onRollback []func()
+}
+
+type txHooks struct {
+	levels     []txHookLevel
+	rolledBack []func() // 已回滚的嵌套事务中注册的onRollback，最外层事务结束时必定执行
+	ready      []func() // 最外层事务已结束，等待执行的钩子
+	locker     sync.Mutex
+}
+
+func (h *txHooks) begin() {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.levels = append(h.levels, txHookLevel{})
+}
+
+func (h *txHooks) add(onCommit, onRollback func()) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	n := len(h.levels)
+	if n == 0 {
+		return
+	}
+	if onCommit != nil {
+		h.levels[n-1].onCommit = append(h.levels[n-1].onCommit, onCommit)
+	}
+	if onRollback != nil {
+		h.levels[n-1].onRollback = append(h.levels[n-1].onRollback, onRollback)
+	}
+}
+
+// commit 提交一层事务，为最外层时onCommit钩子进入待执行队列
+func (h *txHooks) commit() {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	n := len(h.levels)
+	if n == 0 {
+		return
+	}
+	top := h.levels[n-1]
+	h.levels = h.levels[:n-1]
+	if n > 1 {
+		h.levels[n-2].onCommit = append(h.levels[n-2].onCommit, top.onCommit...)
+		h.levels[n-2].onRollback = append(h.levels[n-2].onRollback, top.onRollback...)
+		return
+	}
+	h.ready = append(h.ready, h.rolledBack...)
+	h.ready = append(h.ready, top.onCommit...)
+	h.rolledBack = nil
+}
+
+// rollback 回滚levels层事务，最外层也被回滚时onRollback钩子进入待执行队列
+func (h *txHooks) rollback(levels int) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	for ; levels > 0 && len(h.levels) > 0; levels-- {
+		n := len(h.levels)
+		h.rolledBack = append(h.rolledBack, h.levels[n-1].onRollback...)
+		h.levels = h.levels[:n-1]
+	}
+	if len(h.levels) == 0 {
+		h.ready = append(h.ready, h.rolledBack...)
+		h.rolledBack = nil
+	}
+}
+
+func (h *txHooks) run() {
+	h.locker.Lock()
+	ready := h.ready
+	h.ready = nil
+	h.locker.Unlock()
+	for _, f := range ready {
+		f()
+	}
+}
+
+func (h *txHooks) reset() {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.levels = nil
+	h.rolledBack = nil
+	h.ready = nil
+}
+
+// OnCommit 注册事务提交后执行的函数，在最外层事务提交后执行，不在事务中时立即执行
+func (s *Session) OnCommit(f func()) {
+	if s.InTransaction() {
+		s.txHooks.add(f, nil)
+	} else {
+		f()
+	}
+}
+
+// OnRollback 注册事务回滚后执行的函数，在最外层事务结束后执行，不在事务中时立即执行
+func (s *Session) OnRollback(f func()) {
+	if s.InTransaction() {
+		s.txHooks.add(nil, f)
+	} else {
+		f()
+	}
+}
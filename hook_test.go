@@ -0,0 +1,106 @@
+package sorm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+	"github.com/xkisas/sorm/db"
+)
+
+// hookRecorder 记录钩子的执行顺序
+type hookRecorder []string
+
+func (r *hookRecorder) hook(name string) func() {
+	return func() {
+		*r = append(*r, name)
+	}
+}
+
+func TestTxHooks_NestedCommit(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "hook_commit", builder.MySQL)
+	defer db.Close("hook_commit")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	var fired hookRecorder
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		sess.OnCommit(fired.hook("outer commit"))
+		sess.OnRollback(fired.hook("outer rollback"))
+		err := sess.Transaction(func(sess *Session) error {
+			sess.OnCommit(fired.hook("inner commit"))
+			sess.OnRollback(fired.hook("inner rollback"))
+			return nil
+		})
+		// 内层提交时不执行，等待最外层事务结束
+		ass.Empty(fired)
+		return err
+	}))
+	ass.Equal(hookRecorder{"outer commit", "inner commit"}, fired)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTxHooks_InnerRollback(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "hook_inner_rollback", builder.MySQL)
+	defer db.Close("hook_inner_rollback")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	var fired hookRecorder
+	failed := errors.New("failed")
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		sess.OnCommit(fired.hook("outer commit"))
+		sess.OnRollback(fired.hook("outer rollback"))
+		ass.Equal(failed, sess.Transaction(func(sess *Session) error {
+			sess.OnCommit(fired.hook("inner commit"))
+			sess.OnRollback(fired.hook("inner rollback"))
+			return failed
+		}))
+		ass.Empty(fired)
+		return nil
+	}))
+	// 内层回滚只丢弃自身的onCommit，其onRollback在最外层结束后执行
+	ass.Equal(hookRecorder{"inner rollback", "outer commit"}, fired)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTxHooks_OuterRollback(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "hook_outer_rollback", builder.MySQL)
+	defer db.Close("hook_outer_rollback")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	var fired hookRecorder
+	failed := errors.New("failed")
+	ass.Equal(failed, sess.Transaction(func(sess *Session) error {
+		sess.OnCommit(fired.hook("outer commit"))
+		sess.OnRollback(fired.hook("outer rollback"))
+		if err := sess.Transaction(func(sess *Session) error {
+			sess.OnCommit(fired.hook("inner commit"))
+			sess.OnRollback(fired.hook("inner rollback"))
+			return nil
+		}); err != nil {
+			return err
+		}
+		return failed
+	}))
+	ass.Equal(hookRecorder{"outer rollback", "inner rollback"}, fired)
+	ass.Nil(mock.ExpectationsWereMet())
+
+	// 不在事务中时立即执行
+	fired = nil
+	sess.OnCommit(fired.hook("commit"))
+	sess.OnRollback(fired.hook("rollback"))
+	ass.Equal(hookRecorder{"commit", "rollback"}, fired)
+}
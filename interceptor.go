@@ -0,0 +1,106 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// ErrNoResult 拦截器未调用next，也未返回语句对应的Rows或Result
+var ErrNoResult = errors.New("interceptor returned no result")
+
+type StmtKind int
+
+const (
+	StmtQuery StmtKind = iota
+	StmtExec
+	StmtBegin    // BEGIN 或 SAVEPOINT
+	StmtCommit   // COMMIT 或 RELEASE SAVEPOINT
+	StmtRollback // ROLLBACK 或 ROLLBACK TO SAVEPOINT
+)
+
+func (k StmtKind) String() string {
+	switch k {
+	case StmtQuery:
+		return "query"
+	case StmtExec:
+		return "exec"
+	case StmtBegin:
+		return "begin"
+	case StmtCommit:
+		return "commit"
+	case StmtRollback:
+		return "rollback"
+	}
+	return "unknown"
+}
+
+// Statement session执行的语句，拦截器可修改Query和Args
+type Statement struct {
+	Kind          StmtKind
+	Query         string
+	Args          []interface{}
+	Target        Target
+	InTransaction bool
+	savepoint     bool // 是否为嵌套事务的SAVEPOINT语句
+}
+
+// StmtResult 语句执行结果，查询语句为Rows，其余为Result
+type StmtResult struct {
+	Rows   *sql.Rows
+	Result sql.Result
+}
+
+type Handler func(ctx context.Context, stmt *Statement) (StmtResult, error)
+
+// Interceptor 拦截session执行的语句，调用next继续执行，不调用则直接返回结果
+// 直接返回时查询语句须返回Rows，Exec须返回Result，否则语句返回ErrNoResult
+type Interceptor func(ctx context.Context, stmt *Statement, next Handler) (StmtResult, error)
+
+var (
+	interceptors       []Interceptor
+	interceptorsLocker sync.RWMutex
+)
+
+// AddInterceptor 添加全局拦截器，对所有session生效，先于session拦截器执行
+func AddInterceptor(interceptor ...Interceptor) {
+	interceptorsLocker.Lock()
+	defer interceptorsLocker.Unlock()
+	interceptors = append(interceptors, interceptor...)
+}
+
+func (s *Session) AddInterceptor(interceptor ...Interceptor) *Session {
+	s.interceptors = append(s.interceptors, interceptor...)
+	return s
+}
+
+func chainInterceptors(chain []Interceptor, handler Handler) Handler {
+	for i := len(chain) - 1; i >= 0; i-- {
+		interceptor, next := chain[i], handler
+		handler = func(ctx context.Context, stmt *Statement) (StmtResult, error) {
+			return interceptor(ctx, stmt, next)
+		}
+	}
+	return handler
+}
+
+// execute 经过拦截器链执行语句
+func (s *Session) execute(stmt *Statement, handler Handler) (StmtResult, error) {
+	interceptorsLocker.RLock()
+	chain := make([]Interceptor, 0, len(interceptors)+len(s.interceptors))
+	chain = append(chain, interceptors...)
+	interceptorsLocker.RUnlock()
+	chain = append(chain, s.interceptors...)
+	if len(chain) == 0 {
+		return handler(s.ctx, stmt)
+	}
+	result, err := chainInterceptors(chain, handler)(s.ctx, stmt)
+	if err == nil {
+		switch {
+		case stmt.Kind == StmtQuery && result.Rows == nil, stmt.Kind == StmtExec && result.Result == nil:
+			return result, ErrNoResult
+		}
+	}
+	return result, err
+}
@@ -0,0 +1,109 @@
+package sorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+	"github.com/xkisas/sorm/db"
+)
+
+func TestInterceptor_Order(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "interceptor_order", builder.MySQL)
+	defer db.Close("interceptor_order")
+
+	saved := interceptors
+	defer func() {
+		interceptors = saved
+	}()
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, stmt *Statement, next Handler) (StmtResult, error) {
+			calls = append(calls, name+" before")
+			result, err := next(ctx, stmt)
+			calls = append(calls, name+" after")
+			return result, err
+		}
+	}
+	sess.AddInterceptor(record("session1"), record("session2"))
+	AddInterceptor(record("global"))
+
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := sess.Exec("UPDATE foo SET a=1")
+	ass.Nil(err)
+	// 全局拦截器先于session拦截器，按添加顺序执行
+	ass.Equal([]string{"global before", "session1 before", "session2 before", "session2 after", "session1 after", "global after"}, calls)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestInterceptor_Rewrite(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "interceptor_rewrite", builder.MySQL)
+	defer db.Close("interceptor_rewrite")
+	var logged []LogEntry
+	sess.SetLogger(LoggerFunc(func(ctx context.Context, entry LogEntry) {
+		logged = append(logged, entry)
+	}))
+	sess.AddInterceptor(func(ctx context.Context, stmt *Statement, next Handler) (StmtResult, error) {
+		if stmt.Kind == StmtQuery {
+			stmt.Query = "/* app */ " + stmt.Query
+			stmt.Args = append(stmt.Args, 2)
+		}
+		return next(ctx, stmt)
+	})
+
+	mock.ExpectQuery(`^/\* app \*/ SELECT a FROM foo WHERE a IN \(\?,\?\)$`).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+	rows, err := sess.Query("SELECT a FROM foo WHERE a IN (?,?)", 1)
+	ass.Nil(err)
+	rows.Close()
+	// 日志记录的是改写后的语句
+	if ass.Len(logged, 1) {
+		ass.Equal("/* app */ SELECT a FROM foo WHERE a IN (?,?)", logged[0].Query)
+		ass.Equal([]interface{}{1, 2}, logged[0].Args)
+	}
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestInterceptor_ShortCircuit(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "interceptor_short", builder.MySQL)
+	defer db.Close("interceptor_short")
+	var (
+		result StmtResult
+		err    error
+	)
+	sess.AddInterceptor(func(ctx context.Context, stmt *Statement, next Handler) (StmtResult, error) {
+		return result, err
+	})
+
+	// 直接返回结果，不执行语句
+	result = StmtResult{Result: sqlmock.NewResult(0, 5)}
+	res, e := sess.Exec("UPDATE foo SET a=1")
+	ass.Nil(e)
+	affected, _ := res.RowsAffected()
+	ass.Equal(int64(5), affected)
+
+	// 未返回结果时返回错误，而不是nil的Rows或Result
+	result = StmtResult{}
+	res, e = sess.Exec("UPDATE foo SET a=1")
+	ass.Nil(res)
+	ass.Equal(ErrNoResult, e)
+	rows, e := sess.Query("SELECT a FROM foo")
+	ass.Nil(rows)
+	ass.Equal(ErrNoResult, e)
+	dao := sess.GetDao(new(testUser)).(*Dao)
+	_, e = dao.SelectOne(map[string]interface{}{"name": "foo"})
+	ass.Equal(ErrNoResult, e)
+
+	failed := errors.New("failed")
+	err = failed
+	_, e = sess.Exec("UPDATE foo SET a=1")
+	ass.Equal(failed, e)
+	ass.Nil(mock.ExpectationsWereMet())
+}
@@ -346,3 +346,74 @@ func stringConverter(targetValueField reflect.Value, dataVal interface{}) error
 	}
 	return nil
 }
+
+// AddField 将结构体中tag对应的数值字段加上delta，非数值类型的字段返回ErrNotSupportType
+func AddField(target interface{}, tagName, field string, delta interface{}) error {
+	targetValue := reflect.ValueOf(target)
+	for targetValue.Kind() == reflect.Ptr {
+		targetValue = targetValue.Elem()
+	}
+	if targetValue.Kind() != reflect.Struct {
+		return ErrNotSupportType
+	}
+	targetType := targetValue.Type()
+	for i := 0; i < targetType.NumField(); i++ {
+		tagValue, ok := targetType.Field(i).Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		if idx := strings.IndexByte(tagValue, ','); idx != -1 {
+			if tagValue[:idx] == "pk" {
+				tagValue = tagValue[idx+1:]
+			} else {
+				tagValue = tagValue[:idx]
+			}
+		}
+		if tagValue != field {
+			continue
+		}
+		targetValueField := targetValue.Field(i)
+		if !targetValueField.CanSet() {
+			return ErrTargetNotSettable
+		}
+		return addValue(targetValueField, reflect.ValueOf(delta))
+	}
+	return ErrNotSupportStructField
+}
+
+func addValue(targetValueField, deltaVal reflect.Value) error {
+	deltaKind := deltaVal.Kind()
+	switch kind := targetValueField.Kind(); {
+	case isIntSeriesType(kind) && isIntSeriesType(deltaKind):
+		targetValueField.SetInt(targetValueField.Int() + deltaVal.Int())
+	case isIntSeriesType(kind) && isUintSeriesType(deltaKind):
+		targetValueField.SetInt(targetValueField.Int() + int64(deltaVal.Uint()))
+	case isUintSeriesType(kind) && isIntSeriesType(deltaKind):
+		targetValueField.SetUint(uint64(int64(targetValueField.Uint()) + deltaVal.Int()))
+	case isUintSeriesType(kind) && isUintSeriesType(deltaKind):
+		targetValueField.SetUint(targetValueField.Uint() + deltaVal.Uint())
+	case isFloatSeriesType(kind) && isIntSeriesType(deltaKind):
+		targetValueField.SetFloat(targetValueField.Float() + float64(deltaVal.Int()))
+	case isFloatSeriesType(kind) && isUintSeriesType(deltaKind):
+		targetValueField.SetFloat(targetValueField.Float() + float64(deltaVal.Uint()))
+	case isFloatSeriesType(kind) && isFloatSeriesType(deltaKind):
+		targetValueField.SetFloat(targetValueField.Float() + deltaVal.Float())
+	default:
+		return ErrNotSupportType
+	}
+	return nil
+}
+
+// Negate 返回数值的相反数
+func Negate(v interface{}) (interface{}, error) {
+	val := reflect.ValueOf(v)
+	switch kind := val.Kind(); {
+	case isIntSeriesType(kind):
+		return -val.Int(), nil
+	case isUintSeriesType(kind):
+		return -int64(val.Uint()), nil
+	case isFloatSeriesType(kind):
+		return -val.Float(), nil
+	}
+	return nil, ErrNotSupportType
+}
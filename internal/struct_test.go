@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counter struct {
+	Id    int     `db:"id,pk"`
+	Views int64   `db:"views"`
+	Stock uint    `db:"stock"`
+	Score float64 `db:"score"`
+	Name  string  `db:"name"`
+	Plain int
+}
+
+func TestAddField(t *testing.T) {
+	ass := assert.New(t)
+	c := &counter{Id: 1, Views: 10, Stock: 5, Score: 1.5}
+
+	ass.Nil(AddField(c, "db", "views", 3))
+	ass.Equal(int64(13), c.Views)
+	ass.Nil(AddField(c, "db", "views", uint8(2)))
+	ass.Equal(int64(15), c.Views)
+	ass.Nil(AddField(c, "db", "views", -20))
+	ass.Equal(int64(-5), c.Views)
+
+	ass.Nil(AddField(c, "db", "stock", -2))
+	ass.Equal(uint(3), c.Stock)
+	ass.Nil(AddField(c, "db", "stock", uint64(4)))
+	ass.Equal(uint(7), c.Stock)
+
+	ass.Nil(AddField(c, "db", "score", 2))
+	ass.Equal(3.5, c.Score)
+	ass.Nil(AddField(c, "db", "score", -0.25))
+	ass.Equal(3.25, c.Score)
+
+	// 主键的标签为pk,id
+	ass.Nil(AddField(c, "db", "id", 1))
+	ass.Equal(2, c.Id)
+
+	// 整数字段不接受浮点数
+	ass.Equal(ErrNotSupportType, AddField(c, "db", "views", 0.5))
+	ass.Equal(int64(-5), c.Views)
+	ass.Equal(ErrNotSupportType, AddField(c, "db", "name", 1))
+	ass.Equal(ErrNotSupportType, AddField(c, "db", "views", "1"))
+	ass.Equal(ErrNotSupportStructField, AddField(c, "db", "missing", 1))
+	ass.Equal(ErrNotSupportStructField, AddField(c, "db", "Plain", 1))
+	ass.Equal(ErrNotSupportType, AddField(1, "db", "views", 1))
+}
+
+func TestNegate(t *testing.T) {
+	ass := assert.New(t)
+	tests := []struct {
+		in, out interface{}
+	}{
+		{3, int64(-3)},
+		{int8(-2), int64(2)},
+		{uint32(7), int64(-7)},
+		{1.5, -1.5},
+		{float32(2), float64(-2)},
+	}
+	for _, test := range tests {
+		v, err := Negate(test.in)
+		ass.Nil(err)
+		ass.Equal(test.out, v)
+	}
+	_, err := Negate("1")
+	ass.Equal(ErrNotSupportType, err)
+	_, err = Negate(nil)
+	ass.Equal(ErrNotSupportType, err)
+}
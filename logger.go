@@ -0,0 +1,136 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	LogLevelInfo LogLevel = iota
+	LogLevelWarn
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	}
+	return "INFO"
+}
+
+// Target 语句执行的目标库
+type Target string
+
+const (
+	TargetPrimary Target = "main"
+	TargetReplica Target = "replica"
+)
+
+type LogEntry struct {
+	Level         LogLevel
+	Query         string
+	Args          []interface{}
+	Elapsed       time.Duration
+	RowsAffected  int64 // 查询语句为-1
+	Err           error
+	Target        Target
+	InTransaction bool
+	Slow          bool // 执行时间是否超过慢查询阈值
+}
+
+type Logger interface {
+	Log(ctx context.Context, entry LogEntry)
+}
+
+type LoggerFunc func(ctx context.Context, entry LogEntry)
+
+func (f LoggerFunc) Log(ctx context.Context, entry LogEntry) {
+	f(ctx, entry)
+}
+
+// StdLogger 使用标准库log输出，低于MinLevel的记录将被忽略
+type StdLogger struct {
+	MinLevel LogLevel
+}
+
+func (l StdLogger) Log(ctx context.Context, entry LogEntry) {
+	if entry.Level < l.MinLevel {
+		return
+	}
+	if entry.Err != nil {
+		log.Printf("[%s] %s: %s %v (%s, tx=%t) error: %s\n", entry.Level, entry.Target, entry.Query, entry.Args, entry.Elapsed, entry.InTransaction, entry.Err)
+	} else {
+		log.Printf("[%s] %s: %s %v (%s, tx=%t, rows=%d)\n", entry.Level, entry.Target, entry.Query, entry.Args, entry.Elapsed, entry.InTransaction, entry.RowsAffected)
+	}
+}
+
+var (
+	defaultLogger Logger        // 新建session默认使用的logger
+	slowThreshold time.Duration // 慢查询阈值，0表示不启用
+)
+
+// SetLogger 设置新建session默认使用的logger
+func SetLogger(logger Logger) {
+	defaultLogger = logger
+}
+
+// SetSlowThreshold 设置新建session默认的慢查询阈值，超过阈值的语句以Warn级别记录
+func SetSlowThreshold(threshold time.Duration) {
+	slowThreshold = threshold
+}
+
+func (s *Session) SetLogger(logger Logger) *Session {
+	s.logger = logger
+	return s
+}
+
+func (s *Session) SetSlowThreshold(threshold time.Duration) *Session {
+	s.slowThreshold = threshold
+	return s
+}
+
+func (s *Session) getLogger() Logger {
+	if s.logger != nil {
+		return s.logger
+	} else if s.logSql {
+		return StdLogger{}
+	}
+	return nil
+}
+
+func (s *Session) log(stmt *Statement, elapsed time.Duration, result sql.Result, err error) {
+	logger := s.getLogger()
+	if logger == nil {
+		return
+	}
+	entry := LogEntry{
+		Level:         LogLevelInfo,
+		Query:         stmt.Query,
+		Args:          stmt.Args,
+		Elapsed:       elapsed,
+		RowsAffected:  -1,
+		Err:           err,
+		Target:        stmt.Target,
+		InTransaction: stmt.InTransaction,
+	}
+	if result != nil {
+		if affected, e := result.RowsAffected(); e == nil {
+			entry.RowsAffected = affected
+		}
+	}
+	if s.slowThreshold > 0 && entry.Elapsed >= s.slowThreshold {
+		entry.Slow = true
+		entry.Level = LogLevelWarn
+	}
+	if err != nil {
+		entry.Level = LogLevelError
+	}
+	logger.Log(s.ctx, entry)
+}
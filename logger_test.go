@@ -0,0 +1,133 @@
+package sorm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+	"github.com/xkisas/sorm/db"
+)
+
+// entryRecorder 记录session输出的日志
+type entryRecorder []LogEntry
+
+func (r *entryRecorder) Log(ctx context.Context, entry LogEntry) {
+	*r = append(*r, entry)
+}
+
+func (r *entryRecorder) last() LogEntry {
+	return (*r)[len(*r)-1]
+}
+
+func TestSessionLog(t *testing.T) {
+	ass := assert.New(t)
+	db.DefaultHealthCheckInterval = 0
+	primary, primaryMock, _ := sqlmock.New()
+	replica, replicaMock, _ := sqlmock.New()
+	ass.Nil(db.RegisterDB("session_log", primary, builder.MySQL))
+	defer db.Close("session_log")
+	ass.Nil(db.RegisterReplicaDB("session_log", replica, 1))
+	var entries entryRecorder
+	sess := NewSession(context.TODO()).Use("session_log").SetLogger(&entries)
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	rows, err := sess.QueryReplica("SELECT 1")
+	ass.Nil(err)
+	rows.Close()
+	entry := entries.last()
+	ass.Equal(LogLevelInfo, entry.Level)
+	ass.Equal("SELECT 1", entry.Query)
+	ass.Equal(TargetReplica, entry.Target)
+	ass.False(entry.InTransaction)
+	ass.Equal(int64(-1), entry.RowsAffected)
+
+	primaryMock.ExpectExec("UPDATE").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	_, err = sess.Exec("UPDATE foo SET a=?", 1)
+	ass.Nil(err)
+	entry = entries.last()
+	ass.Equal(TargetPrimary, entry.Target)
+	ass.Equal([]interface{}{1}, entry.Args)
+	ass.Equal(int64(3), entry.RowsAffected)
+	ass.Nil(entry.Err)
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery("SELECT a").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+	primaryMock.ExpectCommit()
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		rows, err := sess.Query("SELECT a FROM foo")
+		if err == nil {
+			rows.Close()
+		}
+		entry = entries.last()
+		return err
+	}))
+	ass.Equal("SELECT a FROM foo", entry.Query)
+	ass.True(entry.InTransaction)
+	ass.Equal(TargetPrimary, entry.Target)
+
+	failed := errors.New("failed")
+	primaryMock.ExpectExec("DELETE").WillReturnError(failed)
+	_, err = sess.Exec("DELETE FROM foo")
+	ass.Equal(failed, err)
+	entry = entries.last()
+	ass.Equal(LogLevelError, entry.Level)
+	ass.Equal(failed, entry.Err)
+
+	ass.Nil(primaryMock.ExpectationsWereMet())
+	ass.Nil(replicaMock.ExpectationsWereMet())
+}
+
+func TestSessionLog_Slow(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "session_log_slow", builder.MySQL)
+	defer db.Close("session_log_slow")
+	var entries entryRecorder
+	sess.SetLogger(&entries).SetSlowThreshold(20 * time.Millisecond)
+
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := sess.Exec("UPDATE foo SET a=1")
+	ass.Nil(err)
+	ass.False(entries.last().Slow)
+	ass.Equal(LogLevelInfo, entries.last().Level)
+
+	mock.ExpectExec("UPDATE").WillDelayFor(30 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = sess.Exec("UPDATE foo SET a=2")
+	ass.Nil(err)
+	entry := entries.last()
+	ass.True(entry.Slow)
+	ass.Equal(LogLevelWarn, entry.Level)
+	ass.True(entry.Elapsed >= 20*time.Millisecond)
+
+	// 出错的慢查询以Error级别记录
+	mock.ExpectExec("UPDATE").WillDelayFor(30 * time.Millisecond).WillReturnError(errors.New("failed"))
+	_, err = sess.Exec("UPDATE foo SET a=3")
+	ass.NotNil(err)
+	ass.True(entries.last().Slow)
+	ass.Equal(LogLevelError, entries.last().Level)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestStdLogger(t *testing.T) {
+	ass := assert.New(t)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	logger := StdLogger{MinLevel: LogLevelWarn}
+	logger.Log(context.TODO(), LogEntry{Level: LogLevelInfo, Query: "SELECT 1"})
+	ass.Empty(buf.String())
+	logger.Log(context.TODO(), LogEntry{Level: LogLevelWarn, Query: "SELECT 2", Target: TargetReplica, RowsAffected: -1, Slow: true})
+	ass.Contains(buf.String(), "[WARN] replica: SELECT 2")
+	buf.Reset()
+	logger.Log(context.TODO(), LogEntry{Level: LogLevelError, Query: "SELECT 3", Target: TargetPrimary, InTransaction: true, Err: errors.New("failed")})
+	ass.Contains(buf.String(), "[ERROR] main: SELECT 3")
+	ass.Contains(buf.String(), "tx=true")
+	ass.Contains(buf.String(), "error: failed")
+}
@@ -0,0 +1,321 @@
+package sorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CacheHit      = "hit"
+	CacheMiss     = "miss"
+	CacheEviction = "eviction"
+)
+
+// MetricsCollector 收集sorm运行时指标
+type MetricsCollector interface {
+	ObserveQuery(table, verb string, elapsed time.Duration, err error)
+	ObserveTransaction(event string, err error) // event为begin、commit、rollback
+	ObserveLazyLoad(table string)
+	ObserveCache(table, event string) // event为hit、miss、eviction
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveQuery(string, string, time.Duration, error) {}
+func (noopMetrics) ObserveTransaction(string, error)                  {}
+func (noopMetrics) ObserveLazyLoad(string)                            {}
+func (noopMetrics) ObserveCache(string, string)                       {}
+
+var (
+	metricsCollector       MetricsCollector = noopMetrics{}
+	metricsCollectorLocker sync.RWMutex
+)
+
+// SetMetrics 设置全局指标收集器，传入nil则关闭收集
+func SetMetrics(collector MetricsCollector) {
+	metricsCollectorLocker.Lock()
+	defer metricsCollectorLocker.Unlock()
+	if collector == nil {
+		collector = noopMetrics{}
+	}
+	metricsCollector = collector
+}
+
+func metrics() MetricsCollector {
+	metricsCollectorLocker.RLock()
+	defer metricsCollectorLocker.RUnlock()
+	return metricsCollector
+}
+
+// observe 记录语句的日志及指标
+func (s *Session) observe(stmt *Statement, start time.Time, result sql.Result, err error) {
+	elapsed := time.Since(start)
+	s.log(stmt, elapsed, result, err)
+	switch stmt.Kind {
+	case StmtQuery, StmtExec:
+		verb, table := parseStatement(stmt.Query)
+		metrics().ObserveQuery(table, verb, elapsed, err)
+	default:
+		if !stmt.savepoint {
+			metrics().ObserveTransaction(stmt.Kind.String(), err)
+		}
+	}
+}
+
+// parseStatement 解析语句的动词及操作的表名
+func parseStatement(query string) (verb, table string) {
+	fields := strings.Fields(trimComments(query))
+	if len(fields) == 0 {
+		return "", ""
+	}
+	verb = strings.ToLower(fields[0])
+	var keyword string
+	switch verb {
+	case "select", "delete", "with":
+		keyword = "from"
+	case "insert", "replace":
+		keyword = "into"
+	case "update":
+		if len(fields) > 1 {
+			table = fields[1]
+		}
+	}
+	if keyword != "" {
+		for i := 1; i < len(fields)-1; i++ {
+			if strings.ToLower(fields[i]) == keyword {
+				table = fields[i+1]
+				break
+			}
+		}
+	}
+	if i := strings.IndexAny(table, "(),"); i >= 0 {
+		table = table[:i]
+	}
+	return verb, strings.Trim(table, "`\"")
+}
+
+// trimComments 去掉语句开头的注释
+func trimComments(query string) string {
+	for {
+		query = strings.TrimSpace(query)
+		switch {
+		case strings.HasPrefix(query, "/*"):
+			i := strings.Index(query, "*/")
+			if i < 0 {
+				return ""
+			}
+			query = query[i+2:]
+		case strings.HasPrefix(query, "--"), strings.HasPrefix(query, "#"):
+			i := strings.IndexByte(query, '\n')
+			if i < 0 {
+				return ""
+			}
+			query = query[i+1:]
+		default:
+			return query
+		}
+	}
+}
+
+// errorClass 返回错误的分类，用于指标统计
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	switch errorKind(err) {
+	case ErrDuplicateKey:
+		return "duplicate_key"
+	case ErrForeignKey:
+		return "foreign_key"
+	case ErrDeadlock:
+		return "deadlock"
+	case ErrLockWaitTimeout:
+		return "lock_wait_timeout"
+	case ErrDataTooLong:
+		return "data_too_long"
+	case ErrConnection:
+		return "connection"
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "context"
+	}
+	return "other"
+}
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// MetricsRegistry 内置的指标收集器，以Prometheus文本格式输出
+type MetricsRegistry struct {
+	buckets      []float64
+	queries      map[string]uint64 // table, verb
+	durations    map[string]*histogram
+	errors       map[string]uint64 // class
+	transactions map[string]uint64 // event
+	lazyLoads    map[string]uint64 // table
+	cache        map[string]uint64 // table, event
+	locker       sync.Mutex
+}
+
+func NewMetricsRegistry(buckets ...float64) *MetricsRegistry {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+	return &MetricsRegistry{
+		buckets:      sorted,
+		queries:      make(map[string]uint64),
+		durations:    make(map[string]*histogram),
+		errors:       make(map[string]uint64),
+		transactions: make(map[string]uint64),
+		lazyLoads:    make(map[string]uint64),
+		cache:        make(map[string]uint64),
+	}
+}
+
+// labelEscaper 按Prometheus文本格式转义标签值，只转义反斜杠、双引号及换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(pairs ...string) string {
+	var str = strings.Builder{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if str.Len() > 0 {
+			str.WriteString(",")
+		}
+		str.WriteString(pairs[i])
+		str.WriteString("=")
+		str.WriteString(`"`)
+		str.WriteString(labelEscaper.Replace(pairs[i+1]))
+		str.WriteString(`"`)
+	}
+	return str.String()
+}
+
+func (r *MetricsRegistry) ObserveQuery(table, verb string, elapsed time.Duration, err error) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	key := labels("table", table, "verb", verb)
+	r.queries[key]++
+	h, ok := r.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.buckets))}
+		r.durations[key] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range r.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+	if err != nil {
+		r.errors[labels("class", errorClass(err))]++
+	}
+}
+
+func (r *MetricsRegistry) ObserveTransaction(event string, err error) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.transactions[labels("event", event)]++
+	if err != nil {
+		r.errors[labels("class", errorClass(err))]++
+	}
+}
+
+func (r *MetricsRegistry) ObserveLazyLoad(table string) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.lazyLoads[labels("table", table)]++
+}
+
+func (r *MetricsRegistry) ObserveCache(table, event string) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.cache[labels("table", table, "event", event)]++
+}
+
+func writeCounter(w io.Writer, name, help string, values map[string]uint64) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s{%s} %d\n", name, k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MetricsRegistry) writeHistogram(w io.Writer, name, help string) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(r.durations))
+	for k := range r.durations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h := r.durations[k]
+		for i, bound := range r.buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			if _, err := fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, k, le, h.counts[i]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n%s_sum{%s} %s\n%s_count{%s} %d\n",
+			name, k, h.count, name, k, strconv.FormatFloat(h.sum, 'g', -1, 64), name, k, h.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export 以Prometheus文本格式输出所有指标
+func (r *MetricsRegistry) Export(w io.Writer) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if err := writeCounter(w, "sorm_queries_total", "Total number of statements executed.", r.queries); err != nil {
+		return err
+	}
+	if err := r.writeHistogram(w, "sorm_query_duration_seconds", "Statement latency in seconds."); err != nil {
+		return err
+	}
+	if err := writeCounter(w, "sorm_errors_total", "Total number of failed statements by error class.", r.errors); err != nil {
+		return err
+	}
+	if err := writeCounter(w, "sorm_transactions_total", "Total number of transactions started, committed and rolled back.", r.transactions); err != nil {
+		return err
+	}
+	if err := writeCounter(w, "sorm_lazy_loads_total", "Total number of lazy loads triggered by model fields.", r.lazyLoads); err != nil {
+		return err
+	}
+	return writeCounter(w, "sorm_model_cache_total", "Total number of model cache hits, misses and evictions.", r.cache)
+}
+
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Export(w)
+}
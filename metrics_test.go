@@ -0,0 +1,85 @@
+package sorm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatement(t *testing.T) {
+	ass := assert.New(t)
+	tests := []struct {
+		query, verb, table string
+	}{
+		{"SELECT * FROM `user` WHERE `id` = ?", "select", "user"},
+		{"INSERT INTO `user`(`name`) VALUES(?)", "insert", "user"},
+		{`INSERT INTO "user"("name") VALUES($1) RETURNING "id"`, "insert", "user"},
+		{"REPLACE INTO user (name) VALUES(?)", "replace", "user"},
+		{"UPDATE `user` SET `name` = ?", "update", "user"},
+		{"DELETE FROM `user` WHERE `id` = ?", "delete", "user"},
+		{"WITH t AS (SELECT * FROM `user`) SELECT * FROM t", "with", "user"},
+		{"select id from user,profile where 1", "select", "user"},
+		{"  \n\tSELECT 1 FROM dual", "select", "dual"},
+		{"/* trace_id=1 */ SELECT * FROM `user`", "select", "user"},
+		{"/*trace*/SELECT * FROM `user`", "select", "user"},
+		{"-- comment\nUPDATE user SET a=1", "update", "user"},
+		{"SELECT 1", "select", ""},
+		{"/* unterminated", "", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		verb, table := parseStatement(test.query)
+		ass.Equal(test.verb, verb, test.query)
+		ass.Equal(test.table, table, test.query)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal(`table="user",verb="select"`, labels("table", "user", "verb", "select"))
+	ass.Equal(`table="a\\b\"c\nd"`, labels("table", "a\\b\"c\nd"))
+	// 非ASCII及其他控制字符原样输出
+	ass.Equal("table=\"用户\tx\"", labels("table", "用户\tx"))
+}
+
+func TestMetricsRegistry_Export(t *testing.T) {
+	ass := assert.New(t)
+	r := NewMetricsRegistry(1, 0.1)
+	r.ObserveQuery("user", "select", 50*time.Millisecond, nil)
+	r.ObserveQuery("user", "select", 500*time.Millisecond, nil)
+	r.ObserveQuery("user", "select", 2*time.Second, nil)
+	r.ObserveQuery(`a"b`, "update", time.Millisecond, &mysql.MySQLError{Number: 1213})
+	r.ObserveTransaction("commit", nil)
+	r.ObserveLazyLoad("user")
+	r.ObserveCache("user", CacheHit)
+
+	var buf bytes.Buffer
+	ass.Nil(r.Export(&buf))
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE sorm_queries_total counter",
+		`sorm_queries_total{table="user",verb="select"} 3`,
+		`sorm_queries_total{table="a\"b",verb="update"} 1`,
+		"# TYPE sorm_query_duration_seconds histogram",
+		// 桶计数为累计值
+		`sorm_query_duration_seconds_bucket{table="user",verb="select",le="0.1"} 1`,
+		`sorm_query_duration_seconds_bucket{table="user",verb="select",le="1"} 2`,
+		`sorm_query_duration_seconds_bucket{table="user",verb="select",le="+Inf"} 3`,
+		`sorm_query_duration_seconds_sum{table="user",verb="select"} 2.55`,
+		`sorm_query_duration_seconds_count{table="user",verb="select"} 3`,
+		`sorm_query_duration_seconds_bucket{table="a\"b",verb="update",le="0.1"} 1`,
+		`sorm_query_duration_seconds_bucket{table="a\"b",verb="update",le="+Inf"} 1`,
+		`sorm_errors_total{class="deadlock"} 1`,
+		`sorm_transactions_total{event="commit"} 1`,
+		`sorm_lazy_loads_total{table="user"} 1`,
+		`sorm_model_cache_total{table="user",event="hit"} 1`,
+	} {
+		ass.Contains(out, line+"\n")
+	}
+	// 桶按上界从小到大输出
+	ass.True(strings.Index(out, `le="0.1"`) < strings.Index(out, `le="1"`))
+}
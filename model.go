@@ -5,6 +5,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/xkisas/sorm/builder"
 	"github.com/xkisas/sorm/internal"
 )
 
@@ -16,6 +17,8 @@ type ModelIfe interface {
 	Loaded() bool
 	Load(opts ...Option) (ModelIfe, error)
 	Update(set map[string]interface{}) (int64, error)
+	Increment(column string, delta interface{}) (int64, error)
+	Decrement(column string, delta interface{}) (int64, error)
 	Remove() error
 	GetId() interface{}
 	InitCustomDao() DaoIfe
@@ -47,8 +50,8 @@ func (bm *BaseModel) Loaded() bool {
 
 func (bm *BaseModel) Load(opts ...Option) (ModelIfe, error) {
 	option := fetchOption(opts...)
-	if option.forUpdate || bm.Loaded() && !option.forceLoad {
-		return bm.dao.Select(option.forUpdate, bm.indexValues...)
+	if option.lock == builder.LockNone && bm.Loaded() && !option.forceLoad {
+		return bm.dao.Select(false, bm.indexValues...)
 	}
 	where, err := bm.dao.buildWhere(bm.indexValues...)
 	if err != nil {
@@ -57,6 +60,12 @@ func (bm *BaseModel) Load(opts ...Option) (ModelIfe, error) {
 	return bm.dao.SelectOne(where, opts...)
 }
 
+// LazyLoad 访问未加载的字段时触发的懒查询
+func LazyLoad(model ModelIfe) (ModelIfe, error) {
+	metrics().ObserveLazyLoad(model.GetDaoIfe().GetTableName())
+	return model.Load()
+}
+
 func (bm *BaseModel) Update(set map[string]interface{}) (int64, error) {
 	model, err := bm.dao.Select(false, bm.indexValues...)
 	if err != nil {
@@ -70,6 +79,23 @@ func (bm *BaseModel) Update(set map[string]interface{}) (int64, error) {
 	return affected, err
 }
 
+// Increment 将数值字段原子地加上delta，已加载的model直接在缓存中更新，无需重新查询
+func (bm *BaseModel) Increment(column string, delta interface{}) (int64, error) {
+	model, err := bm.dao.Select(false, bm.indexValues...)
+	if err != nil {
+		return 0, err
+	}
+	return bm.dao.increment(model, column, delta)
+}
+
+func (bm *BaseModel) Decrement(column string, delta interface{}) (int64, error) {
+	negative, err := internal.Negate(delta)
+	if err != nil {
+		return 0, err
+	}
+	return bm.Increment(column, negative)
+}
+
 func (bm *BaseModel) Remove() error {
 	model, err := bm.dao.Select(false, bm.indexValues...)
 	if err != nil {
@@ -0,0 +1,84 @@
+package sorm
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+	"github.com/xkisas/sorm/db"
+)
+
+func TestBaseModel_Increment(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "model_increment", builder.MySQL)
+	defer db.Close("model_increment")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	model, err := dao.CreateObj(map[string]interface{}{"id": 1, "name": "foo", "views": 10})
+	ass.Nil(err)
+	ass.True(model.Loaded())
+
+	// 单条UPDATE语句，不开启事务
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=`views`+? WHERE `id`=?")).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	affected, err := model.Increment("views", 2)
+	ass.Nil(err)
+	ass.Equal(int64(1), affected)
+	ass.Equal(12, model.(*testUser).Views)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=`views`+? WHERE `id`=?")).
+		WithArgs(int64(-5), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	affected, err = model.Decrement("views", 5)
+	ass.Nil(err)
+	ass.Equal(int64(1), affected)
+	ass.Equal(7, model.(*testUser).Views)
+
+	// 缓存中的model已更新，无需重新查询
+	cached, err := dao.QueryCache(1)
+	ass.Nil(err)
+	ass.Equal(7, cached.(*testUser).Views)
+
+	// 字段无法在内存中累加时清除缓存
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=`views`+? WHERE `id`=?")).
+		WithArgs(0.5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = model.Increment("views", 0.5)
+	ass.Nil(err)
+	_, err = dao.QueryCache(1)
+	ass.Equal(ModelNotFoundError, err)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestBaseModel_IncrementPartial(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "model_increment_partial", builder.MySQL)
+	defer db.Close("model_increment_partial")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	// 插入时只有部分字段，model未完全加载
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_user`(`views`) VALUES(?)")).
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	model, err := dao.Insert(map[string]interface{}{"views": 10})
+	ass.Nil(err)
+	ass.False(model.Loaded())
+	_, err = dao.QueryCache(int64(1))
+	ass.Nil(err)
+
+	// 部分加载的model自增后清除缓存
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_user` SET `views`=`views`+? WHERE `id`=?")).
+		WithArgs(2, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	affected, err := model.Increment("views", 2)
+	ass.Nil(err)
+	ass.Equal(int64(1), affected)
+	_, err = dao.QueryCache(int64(1))
+	ass.Equal(ModelNotFoundError, err)
+	ass.Nil(mock.ExpectationsWereMet())
+}
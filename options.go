@@ -1,10 +1,20 @@
 package sorm
 
+import (
+	"database/sql"
+	"math/rand"
+	"time"
+
+	"github.com/xkisas/sorm/builder"
+)
+
 type option struct {
-	forceMaster bool // 如果存在主从读写分离，是否强制走主库查询
-	forUpdate   bool // 是否给记录添加forUpdate锁
-	forceLoad   bool // 若数据有缓存，是否强制重新查询数据库
-	load        bool // 调用Select方法的同时是否查询数据库记录
+	forceMaster bool             // 如果存在主从读写分离，是否强制走主库查询
+	lock        builder.LockMode // 锁定读的模式，须在事务中使用
+	forceLoad   bool             // 若数据有缓存，是否强制重新查询数据库
+	load        bool             // 调用Select方法的同时是否查询数据库记录
+	batchSize   int              // 批量插入时每条语句插入的记录数
+	allowAll    bool             // 批量更新或删除时是否允许where条件为空
 }
 
 type Option func(o *option)
@@ -12,9 +22,10 @@ type Option func(o *option)
 func fetchOption(opts ...Option) option {
 	opt := option{
 		forceMaster: false,
-		forUpdate:   false,
+		lock:        builder.LockNone,
 		forceLoad:   false,
 		load:        false,
+		batchSize:   defaultInsertBatchSize,
 	}
 	for _, o := range opts {
 		o(&opt)
@@ -29,8 +40,27 @@ func ForceMaster() Option {
 }
 
 func ForUpdate() Option {
+	return Lock(builder.LockForUpdate)
+}
+
+// ForUpdateNoWait 记录已被锁定时立即返回错误
+func ForUpdateNoWait() Option {
+	return Lock(builder.LockForUpdateNoWait)
+}
+
+// ForUpdateSkipLocked 跳过已被锁定的记录
+func ForUpdateSkipLocked() Option {
+	return Lock(builder.LockForUpdateSkipLocked)
+}
+
+func ForShare() Option {
+	return Lock(builder.LockForShare)
+}
+
+// Lock 以指定的模式锁定读取的记录
+func Lock(mode builder.LockMode) Option {
 	return func(o *option) {
-		o.forUpdate = true
+		o.lock = mode
 	}
 }
 
@@ -45,3 +75,97 @@ func Load() Option {
 		o.load = true
 	}
 }
+
+// BatchSize 设置批量插入时每条语句插入的记录数
+func BatchSize(size int) Option {
+	return func(o *option) {
+		if size > 0 {
+			o.batchSize = size
+		}
+	}
+}
+
+// AllowAll 允许批量更新或删除时where条件为空，作用于整张表
+func AllowAll() Option {
+	return func(o *option) {
+		o.allowAll = true
+	}
+}
+
+type txOption struct {
+	isolation     sql.IsolationLevel // 事务隔离级别
+	readOnly      bool               // 是否为只读事务
+	retryAttempts int                // 遇到死锁或锁等待超时时最多执行的次数
+	retryBackoff  Backoff            // 重试前等待的时长
+}
+
+type TxOption func(o *txOption)
+
+// Backoff 根据已失败的次数返回下次重试前等待的时长
+type Backoff func(attempt int) time.Duration
+
+func fetchTxOption(opts ...TxOption) txOption {
+	opt := txOption{
+		isolation:     sql.LevelDefault,
+		readOnly:      false,
+		retryAttempts: 1,
+		retryBackoff:  nil,
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return opt
+}
+
+func (o txOption) sqlTxOptions() *sql.TxOptions {
+	if o.isolation == sql.LevelDefault && !o.readOnly {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: o.isolation, ReadOnly: o.readOnly}
+}
+
+func Isolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOption) {
+		o.isolation = level
+	}
+}
+
+func ReadOnly() TxOption {
+	return func(o *txOption) {
+		o.readOnly = true
+	}
+}
+
+// Retry 事务遇到死锁或锁等待超时时回滚并重新执行，最多执行maxAttempts次
+// backoff为nil时使用FullJitter(ExponentialBackoff(10ms, 1s))
+func Retry(maxAttempts int, backoff Backoff) TxOption {
+	return func(o *txOption) {
+		o.retryAttempts = maxAttempts
+		o.retryBackoff = backoff
+	}
+}
+
+// ExponentialBackoff 以base为基数指数增长，最长不超过max
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// FullJitter 在[0, backoff(attempt)]内随机取等待时长，避免冲突的事务同时重试再次冲突
+func FullJitter(backoff Backoff) Backoff {
+	return func(attempt int) time.Duration {
+		d := backoff(attempt)
+		if d <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int63n(int64(d) + 1))
+	}
+}
@@ -0,0 +1,33 @@
+package sorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	ass := assert.New(t)
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	ass.Equal(10*time.Millisecond, backoff(1))
+	ass.Equal(20*time.Millisecond, backoff(2))
+	ass.Equal(40*time.Millisecond, backoff(3))
+	ass.Equal(50*time.Millisecond, backoff(4))
+	ass.Equal(50*time.Millisecond, backoff(100))
+}
+
+func TestFullJitter(t *testing.T) {
+	ass := assert.New(t)
+	backoff := FullJitter(ExponentialBackoff(10*time.Millisecond, 40*time.Millisecond))
+	for attempt := 1; attempt <= 5; attempt++ {
+		for i := 0; i < 100; i++ {
+			d := backoff(attempt)
+			ass.True(d >= 0 && d <= 40*time.Millisecond, d)
+			if attempt == 1 {
+				ass.True(d <= 10*time.Millisecond, d)
+			}
+		}
+	}
+	ass.Equal(time.Duration(0), FullJitter(func(int) time.Duration { return 0 })(1))
+}
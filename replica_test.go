@@ -0,0 +1,47 @@
+package sorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+	"github.com/xkisas/sorm/db"
+)
+
+func TestQueryReplica_FallbackToPrimary(t *testing.T) {
+	ass := assert.New(t)
+	db.DefaultHealthCheckInterval = 0
+	primary, primaryMock, _ := sqlmock.New()
+	replica, replicaMock, _ := sqlmock.New()
+	ass.Nil(db.RegisterDB("replica_fallback", primary, builder.MySQL))
+	defer db.Close("replica_fallback")
+	ass.Nil(db.RegisterReplicaDB("replica_fallback", replica, 1))
+
+	var target Target
+	sess := NewSession(context.TODO()).Use("replica_fallback").
+		AddInterceptor(func(ctx context.Context, stmt *Statement, next Handler) (StmtResult, error) {
+			target = stmt.Target
+			return next(ctx, stmt)
+		})
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	rows, err := sess.QueryReplica("SELECT 1")
+	ass.Nil(err)
+	rows.Close()
+	ass.Equal(TargetReplica, target)
+
+	ds, _ := sess.DataSource()
+	for _, r := range ds.Replicas() {
+		r.MarkDown()
+	}
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	rows, err = sess.QueryReplica("SELECT 1")
+	ass.Nil(err)
+	rows.Close()
+	ass.Equal(TargetPrimary, target)
+
+	ass.Nil(primaryMock.ExpectationsWereMet())
+	ass.Nil(replicaMock.ExpectationsWereMet())
+}
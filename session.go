@@ -3,23 +3,43 @@ package sorm
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"log"
 	"reflect"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/xkisas/sorm/builder"
 	"github.com/xkisas/sorm/db"
 )
 
-var daoModelLruCacheCapacity = 200
+var (
+	daoModelLruCacheCapacity = 200
+	readYourWritesWindow     time.Duration // 写操作后读请求固定走主库的时长，0表示不启用
+)
 
 type Session struct {
 	tx            *sql.Tx
+	txDepth       int // 事务嵌套层级，大于1时使用SAVEPOINT
 	txMutex       sync.RWMutex
+	txHooks       txHooks
 	daoMap        map[string]DaoIfe
 	daoMapLocker  sync.RWMutex
 	daoModelCache *modelLruCache
 	ctx           context.Context
 	logSql        bool
+	logger        Logger
+	slowThreshold time.Duration
+	interceptors  []Interceptor
+	source        string
+
+	readYourWrites time.Duration
+	lastWrite      int64 // 最近一次写操作的时间(UnixNano)
+	txWrote        int32 // 当前事务中是否执行过写操作
 }
 
 var sessionPool = sync.Pool{
@@ -32,27 +52,85 @@ func SetCacheCapacity(capacity int) {
 	daoModelLruCacheCapacity = capacity
 }
 
+// SetReadYourWrites 设置新建session的默认读写一致性窗口
+func SetReadYourWrites(window time.Duration) {
+	readYourWritesWindow = window
+}
+
 func NewSession(ctx context.Context) *Session {
 	sess := sessionPool.Get().(*Session)
 	sess.ctx = ctx
 	sess.logSql = false
+	sess.logger = defaultLogger
+	sess.slowThreshold = slowThreshold
+	sess.interceptors = nil
+	sess.source = db.DefaultName
+	sess.readYourWrites = readYourWritesWindow
+	sess.lastWrite = 0
+	sess.txWrote = 0
 	sess.daoMap = make(map[string]DaoIfe)
 	return sess
 }
 
 func (s *Session) NewSession() *Session {
-	return NewSession(s.ctx)
+	return NewSession(s.ctx).Use(s.source).SetReadYourWrites(s.readYourWrites).
+		SetLogSql(s.logSql).SetLogger(s.logger).SetSlowThreshold(s.slowThreshold).AddInterceptor(s.interceptors...)
 }
 
-func (s *Session) SetLogSql(b bool) *Session {
-	s.logSql = b
+// Use 指定session使用的数据源，需在开启事务前调用
+func (s *Session) Use(source string) *Session {
+	s.source = source
+	return s
+}
+
+func (s *Session) DataSource() (*db.DataSource, error) {
+	return db.Get(s.source)
+}
+
+func (s *Session) Dialect() builder.Dialect {
+	if ds, err := s.DataSource(); err == nil {
+		return ds.Dialect()
+	}
+	return builder.DefaultDialect()
+}
+
+func (s *Session) hasReplica() bool {
+	ds, err := s.DataSource()
+	return err == nil && ds.HasReplica()
+}
+
+// SetReadYourWrites 设置session的读写一致性窗口，在此session写操作后的window时长内读请求走主库
+func (s *Session) SetReadYourWrites(window time.Duration) *Session {
+	s.readYourWrites = window
 	return s
 }
 
-func (s *Session) log(prefix, query string, args []interface{}) {
-	if s.logSql {
-		log.Println(prefix, query, args)
+func (s *Session) markWrite() {
+	if s.readYourWrites > 0 {
+		atomic.StoreInt64(&s.lastWrite, time.Now().UnixNano())
+	}
+}
+
+// wrote 记录一次写操作，在事务中时提交后重新开始计算一致性窗口，调用时需持有txMutex
+func (s *Session) wrote() {
+	if s.tx != nil {
+		atomic.StoreInt32(&s.txWrote, 1)
 	}
+	s.markWrite()
+}
+
+// pinnedToPrimary 是否处于写操作后的一致性窗口内
+func (s *Session) pinnedToPrimary() bool {
+	if s.readYourWrites <= 0 {
+		return false
+	}
+	lastWrite := atomic.LoadInt64(&s.lastWrite)
+	return lastWrite > 0 && time.Since(time.Unix(0, lastWrite)) < s.readYourWrites
+}
+
+func (s *Session) SetLogSql(b bool) *Session {
+	s.logSql = b
+	return s
 }
 
 func (s *Session) ResetCacheCapacity(capacity int) {
@@ -89,19 +167,38 @@ func (s *Session) GetDao(model ModelIfe) DaoIfe {
 	return dao
 }
 
-func (s *Session) BeginTransaction() error {
+// BeginTransaction 开启事务，已处于事务中时创建SAVEPOINT，此时opts不生效
+func (s *Session) BeginTransaction(opts ...TxOption) error {
 	s.txMutex.Lock()
 	defer s.txMutex.Unlock()
-	return s.txBegin()
+	return s.txBegin(fetchTxOption(opts...).sqlTxOptions())
 }
 
 func (s *Session) RollbackTransaction() error {
+	defer s.txHooks.run()
 	s.txMutex.Lock()
 	defer s.txMutex.Unlock()
 	return s.txRollback()
 }
 
+// rollbackTo 回滚第depth层及其内层的事务，depth为1时回滚整个事务
+func (s *Session) rollbackTo(depth int) error {
+	defer s.txHooks.run()
+	s.txMutex.Lock()
+	defer s.txMutex.Unlock()
+	if depth <= 1 {
+		return s.txRollbackAll()
+	}
+	for s.tx != nil && s.txDepth >= depth {
+		if err := s.txRollback(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Session) SubmitTransaction() error {
+	defer s.txHooks.run()
 	s.txMutex.Lock()
 	defer s.txMutex.Unlock()
 	return s.txCommit()
@@ -113,105 +210,319 @@ func (s *Session) InTransaction() bool {
 	return s.tx != nil
 }
 
+// TransactionDepth 返回当前事务的嵌套层级，不在事务中时为0
+func (s *Session) TransactionDepth() int {
+	s.txMutex.RLock()
+	defer s.txMutex.RUnlock()
+	return s.txDepth
+}
+
 func (s *Session) Close() {
 	s.daoMapLocker.Lock()
 	defer s.daoMapLocker.Unlock()
 
-	s.RollbackTransaction()
+	s.txMutex.Lock()
+	s.txRollbackAll()
+	s.txMutex.Unlock()
+	s.txHooks.run()
+	s.txHooks.reset()
 	s.daoMap = nil
 	s.daoModelCache.Clear()
 	sessionPool.Put(s)
 }
 
 func (s *Session) QueryReplica(query string, args ...interface{}) (*sql.Rows, error) {
-	replicaInstance := db.GetReplicaInstance()
-	if replicaInstance == nil {
+	ds, err := s.DataSource()
+	if err != nil {
+		return nil, err
+	}
+	if !ds.HasReplica() {
 		return nil, NewError(ModelRuntimeError, "replica instance is nil")
 	}
-	s.log("replica:", query, args)
-	return replicaInstance.QueryContext(s.ctx, query, args...)
+	stmt := &Statement{Kind: StmtQuery, Query: query, Args: args, Target: TargetReplica}
+	replica := ds.PickReplica()
+	if replica == nil {
+		// 所有从库均不可用时回退到主库
+		stmt.Target = TargetPrimary
+	}
+	result, err := s.execute(stmt, func(ctx context.Context, stmt *Statement) (StmtResult, error) {
+		var (
+			start    = time.Now()
+			instance = ds.DB()
+		)
+		if replica != nil {
+			instance = replica.DB()
+		}
+		rows, err := instance.QueryContext(ctx, stmt.Query, stmt.Args...)
+		s.observe(stmt, start, nil, err)
+		if replica != nil && errors.Is(err, driver.ErrBadConn) {
+			replica.MarkDown()
+		}
+		return StmtResult{Rows: rows}, err
+	})
+	return result.Rows, wrapError(err, stmt.Query)
 }
 
-func (s *Session) Query(query string, args ...interface{}) (rows *sql.Rows, err error) {
+func (s *Session) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	s.txMutex.RLock()
 	defer s.txMutex.RUnlock()
-	s.log("main:", query, args)
-	if s.tx != nil {
-		rows, err = s.tx.QueryContext(s.ctx, query, args...)
-	} else {
-		rows, err = db.GetInstance().QueryContext(s.ctx, query, args...)
-	}
-	return
+	stmt := &Statement{Kind: StmtQuery, Query: query, Args: args, Target: TargetPrimary, InTransaction: s.tx != nil}
+	result, err := s.execute(stmt, func(ctx context.Context, stmt *Statement) (result StmtResult, err error) {
+		start := time.Now()
+		if s.tx != nil {
+			result.Rows, err = s.tx.QueryContext(ctx, stmt.Query, stmt.Args...)
+		} else {
+			var ds *db.DataSource
+			if ds, err = s.DataSource(); err != nil {
+				return
+			}
+			result.Rows, err = ds.DB().QueryContext(ctx, stmt.Query, stmt.Args...)
+		}
+		s.observe(stmt, start, nil, err)
+		return
+	})
+	return result.Rows, wrapError(err, stmt.Query)
 }
 
-func (s *Session) Exec(query string, args ...interface{}) (result sql.Result, err error) {
+func (s *Session) Exec(query string, args ...interface{}) (sql.Result, error) {
 	s.txMutex.RLock()
 	defer s.txMutex.RUnlock()
-	s.log("main:", query, args)
-	if s.tx != nil {
-		result, err = s.tx.ExecContext(s.ctx, query, args...)
-	} else {
-		result, err = db.GetInstance().ExecContext(s.ctx, query, args...)
+	stmt := &Statement{Kind: StmtExec, Query: query, Args: args, Target: TargetPrimary, InTransaction: s.tx != nil}
+	result, err := s.execute(stmt, func(ctx context.Context, stmt *Statement) (result StmtResult, err error) {
+		start := time.Now()
+		if s.tx != nil {
+			result.Result, err = s.tx.ExecContext(ctx, stmt.Query, stmt.Args...)
+		} else {
+			var ds *db.DataSource
+			if ds, err = s.DataSource(); err != nil {
+				return
+			}
+			result.Result, err = ds.DB().ExecContext(ctx, stmt.Query, stmt.Args...)
+		}
+		s.observe(stmt, start, result.Result, err)
+		return
+	})
+	if err == nil {
+		s.wrote()
 	}
-	return
+	return result.Result, wrapError(err, stmt.Query)
+}
+
+// queryWrite 执行带RETURNING的写语句
+func (s *Session) queryWrite(query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := s.Query(query, args...)
+	if err == nil {
+		s.txMutex.RLock()
+		s.wrote()
+		s.txMutex.RUnlock()
+	}
+	return rows, err
 }
 
 func (s *Session) ClearAllCache() {
 	s.daoModelCache.Clear()
 }
 
+// Transaction 在事务中执行f，f返回错误或panic时回滚，否则提交
+// 已处于事务中时作为嵌套事务执行，此时不会重试
+func (s *Session) Transaction(f func(sess *Session) error, opts ...TxOption) error {
+	option := fetchTxOption(opts...)
+	backoff := option.retryBackoff
+	if backoff == nil {
+		backoff = FullJitter(ExponentialBackoff(10*time.Millisecond, time.Second))
+	}
+	nested := s.InTransaction()
+	for attempt := 1; ; attempt++ {
+		err := s.transaction(f, option.sqlTxOptions())
+		if err == nil || nested || attempt >= option.retryAttempts || !IsRetryableError(err) {
+			return err
+		}
+		select {
+		case <-s.ctx.Done():
+			return err
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+func (s *Session) transaction(f func(sess *Session) error, opts *sql.TxOptions) (err error) {
+	s.txMutex.Lock()
+	err = s.txBegin(opts)
+	depth := s.txDepth
+	s.txMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if recv := recover(); recv != nil {
+			// f中的嵌套事务panic时未结束自身层级，需回滚到开启本层之前
+			s.rollbackTo(depth)
+			if e, ok := recv.(error); ok {
+				err = e
+			} else {
+				err = NewError(ModelRuntimeError, fmt.Sprintf("session.Transaction: panic: %v", recv))
+			}
+		}
+	}()
+	if err = f(s); err != nil {
+		s.rollbackTo(depth)
+		return err
+	}
+	// f中开启的事务未结束，或结束了本层事务
+	if s.TransactionDepth() != depth {
+		s.rollbackTo(depth)
+		return NewError(ModelRuntimeError, "session.Transaction: unbalanced transaction in callback")
+	}
+	return s.SubmitTransaction()
+}
+
+// runInTransaction 在事务中执行f，已处于事务中时使用SAVEPOINT作为嵌套事务，f失败时只回滚自身的修改
 func (s *Session) runInTransaction(f func() error) (err error) {
+	defer s.txHooks.run()
 	s.txMutex.RLock()
 	defer s.txMutex.RUnlock()
-	if s.tx != nil {
-		err = f()
-	} else {
-		err = s.txBegin()
-		if err == nil {
-			err = f()
-			if err != nil {
-				if err := s.txRollback(); err != nil {
-					return err
-				}
-			} else {
-				err = s.txCommit()
-			}
+	if err = s.txBegin(nil); err != nil {
+		return
+	}
+	if err = f(); err != nil {
+		if err := s.txRollback(); err != nil {
+			return err
 		}
+		return
 	}
-	return
+	return s.txCommit()
+}
+
+func savepointName(depth int) string {
+	return "sp_" + strconv.Itoa(depth-1)
+}
+
+// txExec 执行事务控制语句
+func (s *Session) txExec(kind StmtKind, query string, savepoint bool, f func(ctx context.Context) error) error {
+	stmt := &Statement{Kind: kind, Query: query, Target: TargetPrimary, InTransaction: true, savepoint: savepoint}
+	_, err := s.execute(stmt, func(ctx context.Context, stmt *Statement) (StmtResult, error) {
+		start := time.Now()
+		err := f(ctx)
+		s.observe(stmt, start, nil, err)
+		return StmtResult{}, err
+	})
+	return wrapError(err, stmt.Query)
 }
 
-func (s *Session) txBegin() error {
+func (s *Session) txSavepoint(kind StmtKind, query string) error {
+	return s.txExec(kind, query, true, func(ctx context.Context) error {
+		_, err := s.tx.ExecContext(ctx, query)
+		return err
+	})
+}
+
+func (s *Session) txBegin(opts *sql.TxOptions) error {
 	if s.tx != nil {
-		log.Println("session.txBegin: can not begin tx again")
-	} else {
-		var err error
-		if s.tx, err = db.GetInstance().Begin(); err != nil {
+		if err := s.txSavepoint(StmtBegin, "SAVEPOINT "+savepointName(s.txDepth+1)); err != nil {
 			log.Printf("session.txBegin: %s\n", err.Error())
 			return err
 		}
+		s.txDepth++
+		s.daoModelCache.beginTrack()
+		s.txHooks.begin()
+		return nil
+	}
+	ds, err := s.DataSource()
+	if err != nil {
+		log.Printf("session.txBegin: %s\n", err.Error())
+		return err
 	}
+	err = s.txExec(StmtBegin, "BEGIN", false, func(ctx context.Context) (err error) {
+		s.tx, err = ds.DB().BeginTx(ctx, opts)
+		return
+	})
+	if err == nil && s.tx == nil {
+		err = NewError(ModelRuntimeError, "transaction not started")
+	}
+	if err != nil {
+		log.Printf("session.txBegin: %s\n", err.Error())
+		return err
+	}
+	s.txDepth = 1
+	s.daoModelCache.beginTrack()
+	s.txHooks.begin()
 	return nil
 }
 
 func (s *Session) txRollback() error {
-	if s.tx != nil {
-		if err := s.tx.Rollback(); err != nil {
+	if s.tx == nil {
+		return nil
+	}
+	if s.txDepth > 1 {
+		query := "ROLLBACK TO SAVEPOINT " + savepointName(s.txDepth)
+		s.txDepth--
+		s.daoModelCache.rollbackTrack(1)
+		s.txHooks.rollback(1)
+		if err := s.txSavepoint(StmtRollback, query); err != nil {
 			log.Printf("session.txRollback: %s\n", err.Error())
 			return err
 		}
+		return nil
+	}
+	return s.txRollbackAll()
+}
+
+// txRollbackAll 忽略嵌套层级，回滚整个事务
+func (s *Session) txRollbackAll() error {
+	if s.tx != nil {
+		// 事务中缓存的model可能已失效
+		s.daoModelCache.rollbackTrack(s.txDepth)
+		s.txHooks.rollback(s.txDepth)
+		tx := s.tx
+		err := s.txExec(StmtRollback, "ROLLBACK", false, func(context.Context) error {
+			return tx.Rollback()
+		})
+		// 无论成功与否，sql.Tx在Rollback后都不可再用
 		s.tx = nil
+		s.txDepth = 0
+		atomic.StoreInt32(&s.txWrote, 0)
+		if err != nil {
+			log.Printf("session.txRollback: %s\n", err.Error())
+			return err
+		}
 	}
 	return nil
 }
 
 func (s *Session) txCommit() error {
-	if s.tx != nil {
-		if err := s.tx.Commit(); err != nil {
+	if s.tx == nil {
+		return nil
+	}
+	if s.txDepth > 1 {
+		if err := s.txSavepoint(StmtCommit, "RELEASE SAVEPOINT "+savepointName(s.txDepth)); err != nil {
 			log.Printf("session.txCommit: %s\n", err.Error())
 			return err
 		}
+		s.txDepth--
+		s.daoModelCache.commitTrack()
+		s.txHooks.commit()
+		return nil
+	}
+	tx := s.tx
+	err := s.txExec(StmtCommit, "COMMIT", false, func(context.Context) error {
+		return tx.Commit()
+	})
+	if err != nil {
+		s.daoModelCache.rollbackTrack(s.txDepth)
+		s.txHooks.rollback(s.txDepth)
 		s.tx = nil
+		s.txDepth = 0
+		atomic.StoreInt32(&s.txWrote, 0)
+		log.Printf("session.txCommit: %s\n", err.Error())
+		return err
+	}
+	s.tx = nil
+	s.txDepth = 0
+	s.daoModelCache.commitTrack()
+	s.txHooks.commit()
+	if atomic.SwapInt32(&s.txWrote, 0) == 1 {
+		// 事务中有写操作时，窗口从事务提交时开始计算
+		s.markWrite()
 	}
 	return nil
 }
@@ -0,0 +1,316 @@
+package sorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/xkisas/sorm/builder"
+	"github.com/xkisas/sorm/db"
+)
+
+// newMockSession 注册以sqlmock连接的数据源，调用方需在结束时调用db.Close
+func newMockSession(t *testing.T, name string, dialect builder.Dialect) (*Session, sqlmock.Sqlmock) {
+	instance, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.RegisterDB(name, instance, dialect); err != nil {
+		t.Fatal(err)
+	}
+	return NewSession(context.TODO()).Use(name), mock
+}
+
+// expire 使一致性窗口过期
+func expire(s *Session) {
+	atomic.StoreInt64(&s.lastWrite, time.Now().Add(-2*s.readYourWrites).UnixNano())
+}
+
+func TestReadYourWrites_Commit(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "ryw_commit", builder.MySQL)
+	defer db.Close("ryw_commit")
+	sess.SetReadYourWrites(time.Minute)
+
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := sess.Exec("UPDATE foo SET a=1")
+	ass.Nil(err)
+	ass.True(sess.pinnedToPrimary())
+	expire(sess)
+	ass.False(sess.pinnedToPrimary())
+
+	// 只读事务提交后不重新开始计算窗口
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+	mock.ExpectCommit()
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		rows, err := sess.Query("SELECT a FROM foo")
+		if err == nil {
+			rows.Close()
+		}
+		return err
+	}))
+	ass.False(sess.pinnedToPrimary())
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		_, err := sess.Exec("UPDATE foo SET a=2")
+		expire(sess)
+		return err
+	}))
+	ass.True(sess.pinnedToPrimary())
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestReadYourWrites_Returning(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "ryw_returning", builder.PostgreSQL)
+	defer db.Close("ryw_returning")
+	sess.SetReadYourWrites(time.Minute)
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "test_user"\("name", "views"\) VALUES\(\$1,\$2\) RETURNING "id"`).
+		WithArgs("foo", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	model, err := dao.Insert(map[string]interface{}{"name": "foo", "views": 0})
+	ass.Nil(err)
+	ass.Equal(7, model.(*testUser).Id)
+	ass.True(sess.pinnedToPrimary())
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTransaction_Savepoint(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "tx_savepoint", builder.MySQL)
+	defer db.Close("tx_savepoint")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^ROLLBACK TO SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^RELEASE SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^RELEASE SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	ass.Nil(sess.BeginTransaction())
+	ass.Nil(sess.BeginTransaction())
+	ass.Nil(sess.BeginTransaction())
+	ass.Equal(3, sess.TransactionDepth())
+	ass.Nil(sess.RollbackTransaction())
+	ass.Equal(2, sess.TransactionDepth())
+	ass.Nil(sess.BeginTransaction())
+	ass.Nil(sess.SubmitTransaction())
+	ass.Nil(sess.SubmitTransaction())
+	ass.Equal(1, sess.TransactionDepth())
+	ass.Nil(sess.SubmitTransaction())
+	ass.Equal(0, sess.TransactionDepth())
+	ass.False(sess.InTransaction())
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTransaction_RollbackAll(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "tx_rollback_all", builder.MySQL)
+	defer db.Close("tx_rollback_all")
+	dao := sess.GetDao(new(testUser)).(*Dao)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	ass.Nil(sess.BeginTransaction())
+	_, err := dao.CreateObj(map[string]interface{}{"id": 1, "name": "a", "views": 0})
+	ass.Nil(err)
+	ass.Nil(sess.BeginTransaction())
+	_, err = dao.CreateObj(map[string]interface{}{"id": 2, "name": "b", "views": 0})
+	ass.Nil(err)
+
+	sess.txMutex.Lock()
+	ass.Nil(sess.txRollbackAll())
+	sess.txMutex.Unlock()
+	ass.Equal(0, sess.TransactionDepth())
+	ass.False(sess.InTransaction())
+	for _, id := range []int{1, 2} {
+		_, err = dao.QueryCache(id)
+		ass.Equal(ModelNotFoundError, err)
+	}
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTransaction_NestedPanic(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "tx_nested_panic", builder.MySQL)
+	defer db.Close("tx_nested_panic")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	err := sess.Transaction(func(sess *Session) error {
+		return sess.runInTransaction(func() error {
+			panic("boom")
+		})
+	})
+	ass.NotNil(err)
+	ass.Nil(sess.tx)
+	ass.Equal(0, sess.TransactionDepth())
+
+	// 嵌套的Transaction只回滚自身层级，外层事务仍可提交
+	mock.ExpectBegin()
+	mock.ExpectExec("^SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^ROLLBACK TO SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^ROLLBACK TO SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	err = sess.Transaction(func(sess *Session) error {
+		inner := sess.Transaction(func(sess *Session) error {
+			return sess.runInTransaction(func() error {
+				panic("boom")
+			})
+		})
+		ass.NotNil(inner)
+		ass.Equal(1, sess.TransactionDepth())
+		return nil
+	})
+	ass.Nil(err)
+	ass.Nil(sess.tx)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestTransaction_UnclosedNested(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "tx_unclosed", builder.MySQL)
+	defer db.Close("tx_unclosed")
+	failed := errors.New("failed")
+
+	// f返回错误时回滚到本层之前，包括f中未结束的事务
+	mock.ExpectBegin()
+	mock.ExpectExec("^SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	ass.Equal(failed, sess.Transaction(func(sess *Session) error {
+		ass.Nil(sess.BeginTransaction())
+		return failed
+	}))
+	ass.Nil(sess.tx)
+
+	// f成功但未结束开启的事务时不提交
+	mock.ExpectBegin()
+	mock.ExpectExec("^SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	ass.NotNil(sess.Transaction(func(sess *Session) error {
+		return sess.BeginTransaction()
+	}))
+	ass.Nil(sess.tx)
+
+	// 嵌套时只回滚自身层级
+	mock.ExpectBegin()
+	mock.ExpectExec("^SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^ROLLBACK TO SAVEPOINT sp_2$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^ROLLBACK TO SAVEPOINT sp_1$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		ass.Equal(failed, sess.Transaction(func(sess *Session) error {
+			ass.Nil(sess.BeginTransaction())
+			return failed
+		}))
+		ass.Equal(1, sess.TransactionDepth())
+		return nil
+	}))
+	ass.Nil(sess.tx)
+	ass.Nil(mock.ExpectationsWereMet())
+}
+
+func TestIsRetryableError(t *testing.T) {
+	ass := assert.New(t)
+	deadlock := wrapError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, "UPDATE foo SET a=1")
+	lockWait := wrapError(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, "UPDATE foo SET a=1")
+	ass.IsType(&DBError{}, deadlock)
+	ass.IsType(&DBError{}, lockWait)
+	ass.True(IsRetryableError(deadlock))
+	ass.True(IsRetryableError(lockWait))
+	ass.True(IsRetryableError(fmt.Errorf("update: %w", deadlock)))
+	ass.False(IsRetryableError(wrapError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}, "")))
+	ass.False(IsRetryableError(errors.New("deadlock")))
+	ass.False(IsRetryableError(nil))
+}
+
+func TestTransaction_Retry(t *testing.T) {
+	ass := assert.New(t)
+	sess, mock := newMockSession(t, "tx_retry", builder.MySQL)
+	defer db.Close("tx_retry")
+	noWait := func(int) time.Duration { return 0 }
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	// 可重试的错误最多执行maxAttempts次
+	for i := 0; i < 3; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE").WillReturnError(deadlock)
+		mock.ExpectRollback()
+	}
+	attempts := 0
+	err := sess.Transaction(func(sess *Session) error {
+		attempts++
+		_, err := sess.Exec("UPDATE foo SET a=1")
+		return err
+	}, Retry(3, noWait))
+	ass.Equal(3, attempts)
+	ass.True(errors.Is(err, ErrDeadlock))
+	ass.Nil(mock.ExpectationsWereMet())
+
+	// 重试成功后不再执行
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnError(deadlock)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	attempts = 0
+	ass.Nil(sess.Transaction(func(sess *Session) error {
+		attempts++
+		_, err := sess.Exec("UPDATE foo SET a=1")
+		return err
+	}, Retry(3, noWait)))
+	ass.Equal(2, attempts)
+	ass.Nil(mock.ExpectationsWereMet())
+
+	// 不可重试的错误只执行一次
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	attempts = 0
+	failed := errors.New("failed")
+	ass.Equal(failed, sess.Transaction(func(sess *Session) error {
+		attempts++
+		return failed
+	}, Retry(3, noWait)))
+	ass.Equal(1, attempts)
+	ass.Nil(mock.ExpectationsWereMet())
+
+	// 嵌套事务不重试，由最外层事务决定
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE").WillReturnError(deadlock)
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	attempts = 0
+	err = sess.Transaction(func(sess *Session) error {
+		return sess.Transaction(func(sess *Session) error {
+			attempts++
+			_, err := sess.Exec("UPDATE foo SET a=1")
+			return err
+		}, Retry(3, noWait))
+	})
+	ass.Equal(1, attempts)
+	ass.True(IsDeadlock(err))
+	ass.Nil(mock.ExpectationsWereMet())
+}
@@ -22,7 +22,7 @@ func (b *Bool) MustValue() bool {
 
 func (b *Bool) Value() (bool, error) {
 	if !b.loaded && b.model != nil && !b.model.Loaded() {
-		if _, err := b.model.Load(); err != nil {
+		if _, err := sorm.LazyLoad(b.model); err != nil {
 			return false, err
 		}
 	}
@@ -22,7 +22,7 @@ func (f *Float) MustValue() float64 {
 
 func (f *Float) Value() (float64, error) {
 	if !f.loaded && f.model != nil && !f.model.Loaded() {
-		if _, err := f.model.Load(); err != nil {
+		if _, err := sorm.LazyLoad(f.model); err != nil {
 			return 0, err
 		}
 	}
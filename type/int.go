@@ -22,7 +22,7 @@ func (i *Int) MustValue() int {
 
 func (i *Int) Value() (int, error) {
 	if !i.loaded && i.model != nil && !i.model.Loaded() {
-		if _, err := i.model.Load(); err != nil {
+		if _, err := sorm.LazyLoad(i.model); err != nil {
 			return 0, err
 		}
 	}
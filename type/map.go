@@ -23,7 +23,7 @@ func (m *Map) MustValue() map[string]interface{} {
 
 func (m *Map) Value() (map[string]interface{}, error) {
 	if !m.loaded && m.model != nil && !m.model.Loaded() {
-		if _, err := m.model.Load(); err != nil {
+		if _, err := sorm.LazyLoad(m.model); err != nil {
 			return nil, err
 		}
 	}
@@ -23,7 +23,7 @@ func (s *Slice) MustValue() []interface{} {
 
 func (s *Slice) Value() ([]interface{}, error) {
 	if !s.loaded && s.model != nil && !s.model.Loaded() {
-		if _, err := s.model.Load(); err != nil {
+		if _, err := sorm.LazyLoad(s.model); err != nil {
 			return nil, err
 		}
 	}
@@ -22,7 +22,7 @@ func (s *String) MustValue() string {
 
 func (s *String) Value() (string, error) {
 	if !s.loaded && s.model != nil && !s.model.Loaded() {
-		if _, err := s.model.Load(); err != nil {
+		if _, err := sorm.LazyLoad(s.model); err != nil {
 			return "", err
 		}
 	}
@@ -23,7 +23,7 @@ func (t *Time) MustValue() time.Time {
 
 func (t *Time) Value() (time.Time, error) {
 	if !t.loaded && t.model != nil && !t.model.Loaded() {
-		if _, err := t.model.Load(); err != nil {
+		if _, err := sorm.LazyLoad(t.model); err != nil {
 			return time.Time{}, err
 		}
 	}